@@ -0,0 +1,99 @@
+package solana
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Signer signs login challenges on behalf of a Solana wallet.
+type Signer interface {
+	// PublicKey returns the ed25519 public key of the wallet.
+	PublicKey() ed25519.PublicKey
+
+	// SignMessage returns the ed25519 signature of the serialized offchain
+	// message, including its signing domain preamble.
+	SignMessage(ctx context.Context, message []byte) ([]byte, error)
+}
+
+type privateKeySigner struct {
+	key ed25519.PrivateKey
+}
+
+// NewPrivateKeySigner returns a Signer that signs with a raw ed25519 key.
+func NewPrivateKeySigner(key ed25519.PrivateKey) (Signer, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key length %d, expected %d", len(key), ed25519.PrivateKeySize)
+	}
+
+	return &privateKeySigner{key: key}, nil
+}
+
+// NewKeypairFileSigner returns a Signer for a keypair file written by the
+// Solana CLI, which holds the 64 byte secret key as a JSON array of bytes.
+func NewKeypairFileSigner(path string) (Signer, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read keypair file: %w", err)
+	}
+
+	var keypair []byte
+	if err := json.Unmarshal(raw, &keypair); err != nil {
+		return nil, fmt.Errorf("unable to parse keypair file: %w", err)
+	}
+
+	if len(keypair) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid keypair length %d, expected %d", len(keypair), ed25519.PrivateKeySize)
+	}
+
+	// The CLI stores the seed followed by the public key, so the second half
+	// must match the key derived from the first.
+	key := ed25519.NewKeyFromSeed(keypair[:ed25519.SeedSize])
+	if !key.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(keypair[ed25519.SeedSize:])) {
+		return nil, errors.New("keypair public key does not match its secret key")
+	}
+
+	return &privateKeySigner{key: key}, nil
+}
+
+func (s *privateKeySigner) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+func (s *privateKeySigner) SignMessage(_ context.Context, message []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, message), nil
+}
+
+// SignFunc signs a serialized offchain message, for example by forwarding it
+// to a hardware wallet or remote signing service.
+type SignFunc func(ctx context.Context, message []byte) ([]byte, error)
+
+type callbackSigner struct {
+	publicKey ed25519.PublicKey
+	sign      SignFunc
+}
+
+// NewCallbackSigner returns a Signer for the public key that delegates
+// signing to the callback.
+func NewCallbackSigner(publicKey ed25519.PublicKey, sign SignFunc) (Signer, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length %d, expected %d", len(publicKey), ed25519.PublicKeySize)
+	}
+
+	if sign == nil {
+		return nil, errors.New("no sign function provided")
+	}
+
+	return &callbackSigner{publicKey: publicKey, sign: sign}, nil
+}
+
+func (s *callbackSigner) PublicKey() ed25519.PublicKey {
+	return s.publicKey
+}
+
+func (s *callbackSigner) SignMessage(ctx context.Context, message []byte) ([]byte, error) {
+	return s.sign(ctx, message)
+}
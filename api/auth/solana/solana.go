@@ -0,0 +1,184 @@
+// Package solana implements the api.AuthMethod interface for logging in to
+// Vault with the Solana auth plugin, so that Go services do not have to
+// request, sign and redeem login nonces by hand.
+package solana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mr-tron/base58"
+
+	"github.com/callensm/vault-plugin-solana/internal/offchain"
+)
+
+const defaultMountPath = "solana"
+
+// SolanaAuth logs in to the Solana auth backend by signing an offchain
+// message login challenge with a wallet's key.
+type SolanaAuth struct {
+	mountPath string
+	role      string
+	signer    Signer
+}
+
+var _ api.AuthMethod = (*SolanaAuth)(nil)
+
+// LoginOption configures a SolanaAuth.
+type LoginOption func(a *SolanaAuth) error
+
+// NewSolanaAuth returns an auth method that logs in as the signer's wallet.
+// By default the backend is expected to be mounted at auth/solana and no
+// role is requested, which the backend only accepts when role-less login is
+// allowed in its configuration.
+func NewSolanaAuth(signer Signer, opts ...LoginOption) (*SolanaAuth, error) {
+	if signer == nil {
+		return nil, errors.New("no signer provided for login")
+	}
+
+	a := &SolanaAuth{
+		mountPath: defaultMountPath,
+		signer:    signer,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, fmt.Errorf("error with login option: %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+// WithMountPath sets the path the auth backend is mounted at, without the
+// auth/ prefix.
+func WithMountPath(mountPath string) LoginOption {
+	return func(a *SolanaAuth) error {
+		if mountPath == "" {
+			return errors.New("mount path cannot be empty")
+		}
+
+		a.mountPath = mountPath
+		return nil
+	}
+}
+
+// WithRole requests a token for the role rather than the default token
+// parameters of the backend.
+func WithRole(role string) LoginOption {
+	return func(a *SolanaAuth) error {
+		a.role = role
+		return nil
+	}
+}
+
+// Login requests a nonce for the signer's public key, signs the returned
+// challenge and redeems it for a token. The client token is not set by
+// Login itself, but is when called through client.Auth().Login.
+func (a *SolanaAuth) Login(ctx context.Context, client *api.Client) (*api.Secret, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	pubkey := base58.Encode(a.signer.PublicKey())
+
+	nonceData := map[string]any{
+		"public_key": pubkey,
+	}
+	if a.role != "" {
+		nonceData["role"] = a.role
+	}
+
+	nonce, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/nonce", a.mountPath), nonceData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request login nonce: %w", err)
+	}
+
+	if nonce == nil || nonce.Data == nil {
+		return nil, errors.New("empty response from nonce endpoint")
+	}
+
+	challenge, _ := nonce.Data["nonce"].(string)
+	nonceID, _ := nonce.Data["nonce_id"].(string)
+
+	if challenge == "" || nonceID == "" {
+		return nil, errors.New("nonce response is missing the nonce or nonce_id")
+	}
+
+	msg, err := offchain.Message(challenge)
+	if err != nil {
+		return nil, fmt.Errorf("invalid login challenge: %w", err)
+	}
+
+	sig, err := a.signer.SignMessage(ctx, msg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign login challenge: %w", err)
+	}
+
+	loginData := map[string]any{
+		"nonce_id":   nonceID,
+		"public_key": pubkey,
+		"signature":  base58.Encode(sig),
+	}
+	if a.role != "" {
+		loginData["role"] = a.role
+	}
+
+	secret, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", a.mountPath), loginData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to log in with solana auth: %w", err)
+	}
+
+	return secret, nil
+}
+
+// ManageTokenLifecycle logs the client in with the auth method and keeps its
+// token renewed with an api.LifetimeWatcher, logging in again whenever the
+// token reaches its maximum TTL or can no longer be renewed. It blocks until
+// the context is cancelled or a login fails.
+func ManageTokenLifecycle(ctx context.Context, client *api.Client, auth api.AuthMethod) error {
+	for {
+		secret, err := client.Auth().Login(ctx, auth)
+		if err != nil {
+			return err
+		}
+
+		if secret == nil || secret.Auth == nil {
+			return errors.New("login response did not contain a token")
+		}
+
+		watcher, err := client.NewLifetimeWatcher(&api.LifetimeWatcherInput{
+			Secret: secret,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to initialize token lifetime watcher: %w", err)
+		}
+
+		if err := watchToken(ctx, watcher); err != nil {
+			return err
+		}
+	}
+}
+
+// watchToken runs the watcher until the token can no longer be renewed,
+// returning the context's error if it is cancelled first.
+func watchToken(ctx context.Context, watcher *api.LifetimeWatcher) error {
+	go watcher.Start()
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		// Renewal failures are not fatal, since the token is replaced by
+		// logging in again.
+		case <-watcher.DoneCh():
+			return nil
+
+		case <-watcher.RenewCh():
+		}
+	}
+}
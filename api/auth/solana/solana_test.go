@@ -0,0 +1,290 @@
+package solana
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/callensm/vault-plugin-solana/internal/auth"
+)
+
+// newTestVault serves the auth backend mounted at auth/<mountPath> over the
+// Vault HTTP API, returning a client for it and a counter of issued tokens.
+func newTestVault(t *testing.T, mountPath string) (*api.Client, logical.Backend, logical.Storage, *atomic.Int32) {
+	t.Helper()
+
+	config := logical.TestBackendConfig()
+	config.StorageView = new(logical.InmemStorage)
+	config.Logger = hclog.NewNullLogger()
+	config.System = logical.TestSystemView()
+
+	backend, err := auth.Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logins := new(atomic.Int32)
+	prefix := "/v1/auth/" + mountPath + "/"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+
+		var data map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp, err := backend.HandleRequest(r.Context(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       strings.TrimPrefix(r.URL.Path, prefix),
+			Storage:    config.StorageView,
+			MountPoint: "auth/" + mountPath + "/",
+			Data:       data,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err == nil && resp.IsError() {
+			err = resp.Error()
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{err.Error()}})
+			return
+		}
+
+		body := map[string]any{"data": resp.Data}
+		if resp.Auth != nil {
+			body["auth"] = map[string]any{
+				"client_token":   fmt.Sprintf("token-%d", logins.Add(1)),
+				"lease_duration": int(resp.Auth.TTL.Seconds()),
+				"metadata":       resp.Auth.Metadata,
+				"policies":       resp.Auth.Policies,
+				"renewable":      false,
+			}
+		}
+
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+
+	clientConfig := api.DefaultConfig()
+	clientConfig.Address = server.URL
+
+	client, err := api.NewClient(clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ClearToken()
+
+	return client, backend, config.StorageView, logins
+}
+
+func TestSigners(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	msg := []byte("message")
+
+	t.Run("Private Key", func(t *testing.T) {
+		t.Helper()
+
+		signer, err := NewPrivateKeySigner(priv)
+		assert.NoError(t, err)
+		assert.Equal(t, pub, signer.PublicKey())
+
+		sig, err := signer.SignMessage(context.Background(), msg)
+		assert.NoError(t, err)
+		assert.True(t, ed25519.Verify(pub, msg, sig))
+
+		_, err = NewPrivateKeySigner(priv[:32])
+		assert.Error(t, err)
+	})
+
+	t.Run("Keypair File", func(t *testing.T) {
+		t.Helper()
+
+		dir := t.TempDir()
+
+		keypair := make([]int, len(priv))
+		for i, b := range priv {
+			keypair[i] = int(b)
+		}
+
+		raw, err := json.Marshal(keypair)
+		assert.NoError(t, err)
+
+		path := filepath.Join(dir, "id.json")
+		assert.NoError(t, os.WriteFile(path, raw, 0o600))
+
+		signer, err := NewKeypairFileSigner(path)
+		assert.NoError(t, err)
+		assert.Equal(t, pub, signer.PublicKey())
+
+		keypair[63] ^= 0xff
+		raw, err = json.Marshal(keypair)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(path, raw, 0o600))
+
+		_, err = NewKeypairFileSigner(path)
+		assert.ErrorContains(t, err, "does not match")
+
+		_, err = NewKeypairFileSigner(filepath.Join(dir, "missing.json"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Callback", func(t *testing.T) {
+		t.Helper()
+
+		signErr := errors.New("device unavailable")
+
+		signer, err := NewCallbackSigner(pub, func(context.Context, []byte) ([]byte, error) {
+			return nil, signErr
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, pub, signer.PublicKey())
+
+		_, err = signer.SignMessage(context.Background(), msg)
+		assert.ErrorIs(t, err, signErr)
+
+		_, err = NewCallbackSigner(pub, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestLogin(t *testing.T) {
+	client, backend, storage, logins := newTestVault(t, "wallets")
+
+	_, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	signer, err := NewPrivateKeySigner(priv)
+	assert.NoError(t, err)
+
+	_, err = backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]any{
+			"max_nonces_per_key": 20,
+			"nonce_rate_limit":   0,
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/service",
+		Storage:   storage,
+		Data: map[string]any{
+			"bound_public_keys": base58.Encode(signer.PublicKey()),
+			"token_policies":    "service",
+			"token_ttl":         1,
+		},
+	})
+	assert.NoError(t, err)
+
+	t.Run("Login with Role", func(t *testing.T) {
+		t.Helper()
+
+		solanaAuth, err := NewSolanaAuth(signer, WithMountPath("wallets"), WithRole("service"))
+		assert.NoError(t, err)
+
+		secret, err := client.Auth().Login(context.Background(), solanaAuth)
+		assert.NoError(t, err)
+		assert.Contains(t, secret.Auth.Policies, "service")
+		assert.Equal(t, "service", secret.Auth.Metadata["role"])
+		assert.Equal(t, secret.Auth.ClientToken, client.Token())
+	})
+
+	t.Run("Reject Invalid Signatures", func(t *testing.T) {
+		t.Helper()
+
+		invalid, err := NewCallbackSigner(signer.PublicKey(), func(ctx context.Context, message []byte) ([]byte, error) {
+			return signer.SignMessage(ctx, append(message, 0))
+		})
+		assert.NoError(t, err)
+
+		solanaAuth, err := NewSolanaAuth(invalid, WithMountPath("wallets"), WithRole("service"))
+		assert.NoError(t, err)
+
+		_, err = solanaAuth.Login(context.Background(), client)
+		assert.ErrorContains(t, err, "unable to log in with solana auth")
+	})
+
+	t.Run("Sign Multi-line Challenges", func(t *testing.T) {
+		t.Helper()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"nonce_template": "Sign in to Vault\n\nNonce: {{.Nonce}}",
+			},
+		})
+		assert.NoError(t, err)
+
+		solanaAuth, err := NewSolanaAuth(signer, WithMountPath("wallets"), WithRole("service"))
+		assert.NoError(t, err)
+
+		secret, err := solanaAuth.Login(context.Background(), client)
+		assert.NoError(t, err)
+		assert.Contains(t, secret.Auth.Policies, "service")
+	})
+
+	t.Run("Require Mount Path", func(t *testing.T) {
+		t.Helper()
+
+		_, err := NewSolanaAuth(signer, WithMountPath(""))
+		assert.Error(t, err)
+
+		_, err = NewSolanaAuth(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Log in again on Expiry", func(t *testing.T) {
+		t.Helper()
+
+		solanaAuth, err := NewSolanaAuth(signer, WithMountPath("wallets"), WithRole("service"))
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		before := logins.Load()
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- ManageTokenLifecycle(ctx, client, solanaAuth)
+		}()
+
+		assert.Eventually(t, func() bool {
+			return logins.Load() >= before+2
+		}, 10*time.Second, 100*time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-errCh, context.Canceled)
+	})
+}
@@ -1,12 +1,18 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/gagliardetto/solana-go"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/tokenutil"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/callensm/vault-plugin-solana/internal/message"
 	"github.com/callensm/vault-plugin-solana/version"
 )
 
@@ -18,19 +24,117 @@ Vault token issuance via message signature verification.
 )
 
 type AuthConfigEntry struct {
-	TokenPolicies []string `json:"token_policies"`
-	TokenTtl      int      `json:"token_ttl"`
-	TokenMaxTtl   int      `json:"token_max_ttl"`
+	tokenutil.TokenParams
+
+	AllowRolelessLogin   bool          `json:"allow_roleless_login"`
+	Audience             string        `json:"audience"`
+	ClockSkewLeeway      time.Duration `json:"clock_skew_leeway"`
+	LockoutCounterReset  time.Duration `json:"lockout_counter_reset"`
+	LockoutDuration      time.Duration `json:"lockout_duration"`
+	LockoutThreshold     int           `json:"lockout_threshold"`
+	MaxNoncesPerKey      int           `json:"max_nonces_per_key"`
+	MaxPendingPublicKeys int           `json:"max_pending_public_keys"`
+	NonceClientRateBurst int           `json:"nonce_client_rate_burst"`
+	NonceClientRateLimit float64       `json:"nonce_client_rate_limit"`
+	NonceRateLimit       float64       `json:"nonce_rate_limit"`
+	NonceTemplate        string        `json:"nonce_template"`
+	NonceTTL             time.Duration `json:"nonce_ttl"`
+	NonceRateBurst       int           `json:"nonce_rate_burst"`
+	SIWSChainID          string        `json:"siws_chain_id"`
+	SIWSDomain           string        `json:"siws_domain"`
+	SIWSStatement        string        `json:"siws_statement"`
+	SIWSURI              string        `json:"siws_uri"`
+	SessionBaseURL       string        `json:"session_base_url"`
+	SessionIconURL       string        `json:"session_icon_url"`
+	StatelessNonces      bool          `json:"stateless_nonces"`
+}
+
+type LockoutEntry struct {
+	FailedAttempts int   `json:"failed_attempts"`
+	LastFailedAt   int64 `json:"last_failed_at"`
+	LockedUntil    int64 `json:"locked_until"`
+}
+
+type LoginSessionEntry struct {
+	NonceEntry
+
+	PollTokenHash string `json:"poll_token_hash"`
+	Signer        string `json:"signer"`
 }
 
 type NonceEntry struct {
-	ExpiresAt int64  `json:"expires_at"`
-	Nonce     string `json:"nonce"`
-	PublicKey string `json:"public_key"`
+	ID              string `json:"id"`
+	Challenge       string `json:"challenge,omitempty"`
+	ClockSkewLeeway int64  `json:"clock_skew_leeway,omitempty"`
+	ExpiresAt       int64  `json:"expires_at"`
+	IssuedAt        int64  `json:"issued_at"`
+	IssuedAtNano    int64  `json:"issued_at_nano,omitempty"`
+	Mode            string `json:"mode"`
+	Nonce           string `json:"nonce"`
+	PublicKey       string `json:"public_key"`
+	Role            string `json:"role"`
+
+	// Signatures holds the member signatures collected so far for a
+	// multi-signature role, keyed by public key.
+	Signatures map[string]string `json:"signatures,omitempty"`
+
+	// SignIn is the SIWS input issued with a siws mode nonce, which the
+	// signed message must match regardless of later config changes.
+	SignIn *message.SignInInput `json:"sign_in,omitempty"`
+
+	stateless bool
+}
+
+type RoleEntry struct {
+	tokenutil.TokenParams
+
+	BoundGovernanceRealm         string        `json:"bound_governance_realm"`
+	BoundNFTCollection           string        `json:"bound_nft_collection"`
+	BoundProgramUpgradeAuthority string        `json:"bound_program_upgrade_authority"`
+	BoundPublicKeys              []string      `json:"bound_public_keys"`
+	BoundSquadsMultisig          string        `json:"bound_squads_multisig"`
+	BoundTokenMint               string        `json:"bound_token_mint"`
+	ClockSkewLeeway              time.Duration `json:"clock_skew_leeway"`
+	CosignerPublicKeys           []string      `json:"cosigner_public_keys"`
+	GovernanceProgramID          string        `json:"governance_program_id"`
+	GoverningTokenMint           string        `json:"governing_token_mint"`
+	MinGoverningTokenDeposit     uint64        `json:"min_governing_token_deposit"`
+	MinTokenBalance              uint64        `json:"min_token_balance"`
+	NonceTTL                     time.Duration `json:"nonce_ttl"`
+	SignatureThreshold           int           `json:"signature_threshold"`
+	SquadsPermissions            []string      `json:"squads_permissions"`
+	SquadsProgramID              string        `json:"squads_program_id"`
+}
+
+type RPCConfigEntry struct {
+	CacheTTL     time.Duration `json:"cache_ttl"`
+	CheckTimeout time.Duration `json:"check_timeout"`
+	Commitment   string        `json:"commitment"`
+	Endpoints    []RPCEndpoint `json:"endpoints"`
+	MaxRetries   int           `json:"max_retries"`
+	RetryBackoff time.Duration `json:"retry_backoff"`
+	Timeout      time.Duration `json:"timeout"`
+}
+
+type RPCEndpoint struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	URL     string            `json:"url"`
 }
 
 type SolanaAuthBackend struct {
 	*framework.Backend
+
+	accountCache     ttlCache[*rpcAccount]
+	tidy             tidyStatus
+	usedNonces       usedNonceCache
+	nonceKeyLimiter  keyedRateLimiter
+	nonceAddrLimiter keyedRateLimiter
+	pendingKeys      pendingCounter
+	pendingSessions  pendingCounter
+	lockoutLock      sync.Mutex
+	multisigLock     sync.Mutex
+	nonceKeyLock     sync.Mutex
+	sessionLock      sync.Mutex
 }
 
 func newSolanaAuthBackend() *SolanaAuthBackend {
@@ -38,23 +142,75 @@ func newSolanaAuthBackend() *SolanaAuthBackend {
 	s.Backend = &framework.Backend{
 		Help: strings.TrimSpace(backendHelp),
 		PathsSpecial: &logical.Paths{
-			LocalStorage: []string{},
+			// Each cluster signs stateless nonces with its own key, since
+			// redeemed nonces are only remembered by the node redeeming them.
+			LocalStorage: []string{
+				"nonce_key",
+			},
 			SealWrapStorage: []string{
 				"config",
+				"config/rpc",
+				"login_session/",
+				"multisig_nonce/",
 				"nonce/",
+				"nonce_key",
+				"role/",
 			},
 			Unauthenticated: []string{
 				"login",
+				"login/session",
+				"login/session/*",
 				"nonce",
 			},
 		},
-		Paths: []*framework.Path{
-			pathConfig(&s),
-			pathLogin(&s),
-			pathNonce(&s),
-		},
+		Paths: framework.PathAppend(
+			[]*framework.Path{
+				pathConfig(&s),
+				pathConfigRPC(&s),
+				pathLogin(&s),
+				pathNonce(&s),
+			},
+			pathLockout(&s),
+			pathLoginSession(&s),
+			pathRole(&s),
+			pathTidy(&s),
+		),
+		AuthRenew:      s.pathLoginRenew,
+		PeriodicFunc:   s.periodicFunc,
 		BackendType:    logical.TypeCredential,
 		RunningVersion: fmt.Sprintf("v%s", version.Version),
 	}
 	return &s
 }
+
+// parsePublicKey strictly decodes a base-58 ed25519 public key, rejecting
+// non-canonical encodings and points that are not on the curve.
+func parsePublicKey(pubkey string) (solana.PublicKey, error) {
+	pk, err := solana.PublicKeyFromBase58(pubkey)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+
+	if pk.String() != pubkey {
+		return solana.PublicKey{}, errors.New("non-canonical base-58 encoding")
+	}
+
+	if !solana.IsOnCurve(pk[:]) {
+		return solana.PublicKey{}, errors.New("not an ed25519 public key")
+	}
+
+	return pk, nil
+}
+
+// upgradeTokenParams converts TTLs persisted before the adoption of
+// tokenutil.TokenParams, which were stored as whole seconds rather than
+// as a time.Duration.
+func upgradeTokenParams(t *tokenutil.TokenParams) {
+	if t.TokenTTL > 0 && t.TokenTTL < time.Second {
+		t.TokenTTL *= time.Second
+	}
+
+	if t.TokenMaxTTL > 0 && t.TokenMaxTTL < time.Second {
+		t.TokenMaxTTL *= time.Second
+	}
+}
@@ -2,10 +2,14 @@ package auth
 
 import (
 	"context"
+	"maps"
 	"testing"
 
+	"github.com/gagliardetto/solana-go"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
 )
 
 func getTestBackend(tb testing.TB) (*SolanaAuthBackend, logical.Storage) {
@@ -23,3 +27,63 @@ func getTestBackend(tb testing.TB) (*SolanaAuthBackend, logical.Storage) {
 
 	return b.(*SolanaAuthBackend), config.StorageView
 }
+
+// allowRolelessLogin enables login without a role for tests that exercise
+// the login flow itself rather than role enforcement.
+func allowRolelessLogin(tb testing.TB, b *SolanaAuthBackend, storage logical.Storage) {
+	tb.Helper()
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]any{
+			"allow_roleless_login": true,
+		},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func loginWithWallet(tb testing.TB, b *SolanaAuthBackend, storage logical.Storage, wallet *solana.Wallet, data map[string]any) (*logical.Response, error) {
+	tb.Helper()
+
+	nonceData := map[string]any{
+		"public_key": wallet.PublicKey().String(),
+	}
+	maps.Copy(nonceData, data)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "nonce",
+		Storage:   storage,
+		Data:      nonceData,
+	})
+	if err != nil || resp.IsError() {
+		return resp, err
+	}
+
+	msg := message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+		MessageBody: []byte(resp.Data["nonce"].(string)),
+		Version:     0,
+	})
+
+	signature, err := wallet.PrivateKey.Sign(msg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	loginData := map[string]any{
+		"nonce_id":  resp.Data["nonce_id"].(string),
+		"signature": signature.String(),
+	}
+	maps.Copy(loginData, nonceData)
+
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   storage,
+		Data:      loginData,
+	})
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	tokenAccountSize         = 165
+	tokenAccountStateOffset  = 108
+	tokenAccountStateInvalid = 0
+)
+
+func addOnChainBindingFields(fields map[string]*framework.FieldSchema) {
+	fields["bound_governance_realm"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base-58 address of an SPL Governance realm in which the public key must have deposited at least min_governing_token_deposit",
+	}
+	fields["bound_nft_collection"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base-58 address of a Metaplex collection of which the public key must hold a verified NFT",
+	}
+	fields["bound_program_upgrade_authority"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base-58 address of a BPF upgradeable program whose current upgrade authority the public key must be",
+	}
+	fields["bound_squads_multisig"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base-58 address of a Squads v4 multisig of which the public key must be a member",
+	}
+	fields["bound_token_mint"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base-58 address of an SPL token mint of which the public key must hold at least min_token_balance",
+	}
+	fields["governance_program_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base-58 address of the SPL Governance program instance that owns bound_governance_realm",
+		Default:     defaultGovernanceProgramID,
+	}
+	fields["governing_token_mint"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base-58 address of the community or council mint of bound_governance_realm",
+	}
+	fields["min_governing_token_deposit"] = &framework.FieldSchema{
+		Type:        framework.TypeInt64,
+		Description: "Minimum amount of governing_token_mint, in base units, that the public key must have deposited in bound_governance_realm",
+		Default:     1,
+	}
+	fields["min_token_balance"] = &framework.FieldSchema{
+		Type:        framework.TypeInt64,
+		Description: "Minimum balance of bound_token_mint, in base units, that the public key must hold",
+		Default:     1,
+	}
+	fields["squads_permissions"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: "Comma-separated list of permissions (initiate, vote, execute) the public key must hold on bound_squads_multisig",
+	}
+	fields["squads_program_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base-58 address of the Squads v4 program that owns bound_squads_multisig",
+		Default:     defaultSquadsProgramID,
+	}
+}
+
+// parseOnChainBindings updates the on-chain bindings of the role from the
+// request data and validates the result.
+func (r *RoleEntry) parseOnChainBindings(data *framework.FieldData) error {
+	for field, value := range map[string]*string{
+		"bound_governance_realm":          &r.BoundGovernanceRealm,
+		"bound_nft_collection":            &r.BoundNFTCollection,
+		"bound_program_upgrade_authority": &r.BoundProgramUpgradeAuthority,
+		"bound_squads_multisig":           &r.BoundSquadsMultisig,
+		"bound_token_mint":                &r.BoundTokenMint,
+		"governance_program_id":           &r.GovernanceProgramID,
+		"governing_token_mint":            &r.GoverningTokenMint,
+		"squads_program_id":               &r.SquadsProgramID,
+	} {
+		if raw, ok := data.GetOk(field); ok {
+			*value = raw.(string)
+		}
+
+		if *value == "" {
+			continue
+		}
+
+		if _, err := solana.PublicKeyFromBase58(*value); err != nil {
+			return fmt.Errorf("invalid %s: %w", field, err)
+		}
+	}
+
+	for field, value := range map[string]*uint64{
+		"min_governing_token_deposit": &r.MinGoverningTokenDeposit,
+		"min_token_balance":           &r.MinTokenBalance,
+	} {
+		if raw, ok := data.GetOk(field); ok {
+			if raw.(int64) < 1 {
+				return fmt.Errorf("%s must be at least 1", field)
+			}
+			*value = uint64(raw.(int64))
+		}
+
+		if *value == 0 {
+			*value = 1
+		}
+	}
+
+	if raw, ok := data.GetOk("squads_permissions"); ok {
+		if _, err := parseSquadsPermissions(raw.([]string)); err != nil {
+			return err
+		}
+		r.SquadsPermissions = raw.([]string)
+	}
+
+	if r.BoundSquadsMultisig != "" && r.SquadsProgramID == "" {
+		r.SquadsProgramID = defaultSquadsProgramID
+	}
+
+	if r.BoundGovernanceRealm != "" {
+		if r.GoverningTokenMint == "" {
+			return errors.New("governing_token_mint is required with bound_governance_realm")
+		}
+
+		if r.GovernanceProgramID == "" {
+			r.GovernanceProgramID = defaultGovernanceProgramID
+		}
+	}
+
+	return nil
+}
+
+func (r *RoleEntry) populateOnChainBindings(m map[string]any) {
+	m["bound_governance_realm"] = r.BoundGovernanceRealm
+	m["bound_nft_collection"] = r.BoundNFTCollection
+	m["bound_program_upgrade_authority"] = r.BoundProgramUpgradeAuthority
+	m["bound_squads_multisig"] = r.BoundSquadsMultisig
+	m["bound_token_mint"] = r.BoundTokenMint
+	m["governance_program_id"] = r.GovernanceProgramID
+	m["governing_token_mint"] = r.GoverningTokenMint
+	m["min_governing_token_deposit"] = r.MinGoverningTokenDeposit
+	m["min_token_balance"] = r.MinTokenBalance
+	m["squads_permissions"] = r.SquadsPermissions
+	m["squads_program_id"] = r.SquadsProgramID
+}
+
+// hasOnChainBindings reports whether the role has constraints that must be
+// evaluated against on-chain state.
+func (r *RoleEntry) hasOnChainBindings() bool {
+	return r.BoundGovernanceRealm != "" ||
+		r.BoundNFTCollection != "" ||
+		r.BoundProgramUpgradeAuthority != "" ||
+		r.BoundSquadsMultisig != "" ||
+		r.BoundTokenMint != ""
+}
+
+// checkOnChainBindings evaluates the on-chain constraints of the role for
+// the public key, returning a deniedError describing the first that fails.
+func (s *SolanaAuthBackend) checkOnChainBindings(ctx context.Context, store logical.Storage, role *RoleEntry, pk solana.PublicKey) error {
+	if role == nil || !role.hasOnChainBindings() {
+		return nil
+	}
+
+	config, err := s.getRPCConfig(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return errors.New("role has on-chain bindings but config/rpc has not been configured")
+	}
+
+	// Each request may be retried against every endpoint, so the checks are
+	// bounded as a whole to keep a dead endpoint from stalling logins.
+	ctx, cancel := context.WithTimeout(ctx, config.CheckTimeout)
+	defer cancel()
+
+	client := s.newRPCClient(config)
+
+	if role.BoundTokenMint != "" {
+		if err := checkTokenBalance(ctx, client, pk, solana.MustPublicKeyFromBase58(role.BoundTokenMint), role.MinTokenBalance); err != nil {
+			return err
+		}
+	}
+
+	if role.BoundNFTCollection != "" {
+		if err := checkNFTCollection(ctx, client, pk, solana.MustPublicKeyFromBase58(role.BoundNFTCollection)); err != nil {
+			return err
+		}
+	}
+
+	if role.BoundGovernanceRealm != "" {
+		program := solana.MustPublicKeyFromBase58(role.GovernanceProgramID)
+		realm := solana.MustPublicKeyFromBase58(role.BoundGovernanceRealm)
+		mint := solana.MustPublicKeyFromBase58(role.GoverningTokenMint)
+
+		if err := checkGovernanceMembership(ctx, client, pk, program, realm, mint, role.MinGoverningTokenDeposit); err != nil {
+			return err
+		}
+	}
+
+	if role.BoundSquadsMultisig != "" {
+		program := solana.MustPublicKeyFromBase58(role.SquadsProgramID)
+		multisig := solana.MustPublicKeyFromBase58(role.BoundSquadsMultisig)
+		permissions, _ := parseSquadsPermissions(role.SquadsPermissions)
+
+		if err := checkSquadsMembership(ctx, client, pk, program, multisig, permissions); err != nil {
+			return err
+		}
+	}
+
+	if role.BoundProgramUpgradeAuthority != "" {
+		if err := checkUpgradeAuthority(ctx, client, pk, solana.MustPublicKeyFromBase58(role.BoundProgramUpgradeAuthority)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkTokenBalance requires the owner to hold at least min base units of
+// the mint across all of its SPL token accounts.
+func checkTokenBalance(ctx context.Context, client rpcClient, owner, mint solana.PublicKey, min uint64) error {
+	accounts, err := client.getTokenAccountsByOwner(ctx, owner, rpcTokenFilter{Mint: mint})
+	if err != nil {
+		return fmt.Errorf("failed to fetch token accounts: %w", err)
+	}
+
+	var balance uint64
+	for _, account := range accounts {
+		token, ok := decodeTokenAccount(account.Account)
+		if !ok || !token.Mint.Equals(mint) || !token.Owner.Equals(owner) {
+			continue
+		}
+
+		if balance+token.Amount < balance {
+			balance = ^uint64(0)
+			break
+		}
+		balance += token.Amount
+	}
+
+	if balance < min {
+		return &deniedError{fmt.Sprintf("balance of token %s is below the required %d", mint, min)}
+	}
+
+	return nil
+}
+
+type tokenAccount struct {
+	Amount uint64
+	Mint   solana.PublicKey
+	Owner  solana.PublicKey
+}
+
+// decodeTokenAccount decodes an initialized SPL token or Token-2022 account,
+// returning false if the account is not one.
+func decodeTokenAccount(account *rpcAccount) (*tokenAccount, bool) {
+	if account == nil || (!account.Owner.Equals(solana.TokenProgramID) && !account.Owner.Equals(solana.Token2022ProgramID)) {
+		return nil, false
+	}
+
+	data := account.Data
+	if len(data) < tokenAccountSize || data[tokenAccountStateOffset] == tokenAccountStateInvalid {
+		return nil, false
+	}
+
+	return &tokenAccount{
+		Amount: binary.LittleEndian.Uint64(data[64:72]),
+		Mint:   solana.PublicKeyFromBytes(data[0:32]),
+		Owner:  solana.PublicKeyFromBytes(data[32:64]),
+	}, true
+}
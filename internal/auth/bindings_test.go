@@ -0,0 +1,507 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenGatedRole(t *testing.T) {
+	backend, storage := getTestBackend(t)
+
+	holder := solana.NewWallet()
+	other := solana.NewWallet()
+	mint := solana.NewWallet().PublicKey()
+
+	writeRole := func(data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/holders",
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	t.Run("Require RPC Configuration", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{
+			"bound_token_mint": mint.String(),
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		_, err = loginWithWallet(t, backend, storage, holder, map[string]any{"role": "holders"})
+		assert.ErrorContains(t, err, "config/rpc")
+	})
+
+	rpc := configureFakeRPC(t, backend, storage)
+	rpc.setTokenAccount(holder.PublicKey(), mint, 60)
+	rpc.setTokenAccount(holder.PublicKey(), mint, 40)
+	rpc.setTokenAccount(other.PublicKey(), mint, 10)
+	rpc.setTokenAccount(other.PublicKey(), solana.NewWallet().PublicKey(), 1000)
+
+	t.Run("Reject Invalid Mint", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{"bound_token_mint": "not-a-mint"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Admit Holder", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{"min_token_balance": 100, "token_policies": "holder"})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		resp, err = loginWithWallet(t, backend, storage, holder, map[string]any{"role": "holders"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+		assert.Contains(t, resp.Auth.Policies, "holder")
+	})
+
+	t.Run("Deny Insufficient Balance", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := loginWithWallet(t, backend, storage, other, map[string]any{"role": "holders"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "below the required 100")
+	})
+
+	t.Run("Enforce Bound Public Keys", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{
+			"bound_public_keys": other.PublicKey().String(),
+			"min_token_balance": 1,
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		resp, err = loginWithWallet(t, backend, storage, holder, map[string]any{"role": "holders"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Bound On-chain Checks of a Login", func(t *testing.T) {
+		t.Helper()
+
+		release := make(chan struct{})
+		hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}))
+		t.Cleanup(hanging.Close)
+		t.Cleanup(func() { close(release) })
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/rpc",
+			Storage:   storage,
+			Data: map[string]any{
+				"check_timeout": 1,
+				"endpoints":     []any{hanging.URL, hanging.URL},
+				"max_retries":   maxRPCRetries,
+				"timeout":       30,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		start := time.Now()
+		_, err = loginWithWallet(t, backend, storage, other, map[string]any{"role": "holders"})
+
+		assert.ErrorContains(t, err, "failed to fetch token accounts")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestNFTCollectionRole(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	rpc := configureFakeRPC(t, backend, storage)
+
+	member := solana.NewWallet()
+	unverified := solana.NewWallet()
+	collection := solana.NewWallet().PublicKey()
+
+	rpc.setTokenAccount(member.PublicKey(), solana.NewWallet().PublicKey(), 500)
+	rpc.setNFT(member.PublicKey(), solana.NewWallet().PublicKey(), true)
+	rpc.setNFT(member.PublicKey(), collection, true)
+	rpc.setNFT(unverified.PublicKey(), collection, false)
+
+	resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/collectors",
+		Storage:   storage,
+		Data: map[string]any{
+			"bound_nft_collection": collection.String(),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	t.Run("Admit Verified Member", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := loginWithWallet(t, backend, storage, member, map[string]any{"role": "collectors"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+
+	t.Run("Deny Unverified Member", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := loginWithWallet(t, backend, storage, unverified, map[string]any{"role": "collectors"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "no verified NFT")
+	})
+
+	t.Run("Fetch Metadata Accounts without Cache", func(t *testing.T) {
+		t.Helper()
+
+		calls := rpc.callCount("getMultipleAccounts")
+
+		resp, err := loginWithWallet(t, backend, storage, member, map[string]any{"role": "collectors"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+		assert.Equal(t, calls+1, rpc.callCount("getMultipleAccounts"))
+	})
+
+	t.Run("Cache Metadata Accounts", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/rpc",
+			Storage:   storage,
+			Data: map[string]any{
+				"cache_ttl": 60,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		_, err = loginWithWallet(t, backend, storage, member, map[string]any{"role": "collectors"})
+		assert.NoError(t, err)
+
+		calls := rpc.callCount("getMultipleAccounts")
+
+		resp, err = loginWithWallet(t, backend, storage, member, map[string]any{"role": "collectors"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+		assert.Equal(t, calls, rpc.callCount("getMultipleAccounts"))
+	})
+}
+
+func TestDecodeMetadataCollection(t *testing.T) {
+	rpc := newFakeRPC(t)
+
+	owner := solana.NewWallet().PublicKey()
+	collection := solana.NewWallet().PublicKey()
+	mint := rpc.setNFT(owner, collection, true)
+
+	address, _, err := solana.FindTokenMetadataAddress(mint)
+	assert.NoError(t, err)
+
+	t.Run("Decode Collection", func(t *testing.T) {
+		t.Helper()
+
+		decodedMint, c, err := decodeMetadataCollection(rpc.accounts[address].Data)
+
+		assert.NoError(t, err)
+		assert.Equal(t, mint, decodedMint)
+		assert.Equal(t, &metadataCollection{Key: collection, Verified: true}, c)
+	})
+
+	t.Run("Reject Truncated Data", func(t *testing.T) {
+		t.Helper()
+
+		_, _, err := decodeMetadataCollection(rpc.accounts[address].Data[:70])
+		assert.Error(t, err)
+	})
+}
+
+func TestGovernanceRole(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	rpc := configureFakeRPC(t, backend, storage)
+
+	council := solana.NewWallet()
+	member := solana.NewWallet()
+	outsider := solana.NewWallet()
+	realm := solana.NewWallet().PublicKey()
+	mint := solana.NewWallet().PublicKey()
+
+	rpc.setTokenOwnerRecord(council.PublicKey(), realm, mint, 5)
+	rpc.setTokenOwnerRecord(member.PublicKey(), realm, mint, 1)
+
+	writeRole := func(data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/council",
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	t.Run("Require Governing Token Mint", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{"bound_governance_realm": realm.String()})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	resp, err := writeRole(map[string]any{
+		"bound_governance_realm":      realm.String(),
+		"governing_token_mint":        mint.String(),
+		"min_governing_token_deposit": 5,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	t.Run("Admit Council Member", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := loginWithWallet(t, backend, storage, council, map[string]any{"role": "council"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+
+	t.Run("Deny Insufficient Deposit", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := loginWithWallet(t, backend, storage, member, map[string]any{"role": "council"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "below the required 5")
+	})
+
+	t.Run("Deny Missing Token Owner Record", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := loginWithWallet(t, backend, storage, outsider, map[string]any{"role": "council"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "no token owner record")
+	})
+}
+
+func TestSquadsMultisigRole(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	rpc := configureFakeRPC(t, backend, storage)
+
+	voter := solana.NewWallet()
+	executor := solana.NewWallet()
+	outsider := solana.NewWallet()
+
+	multisig := rpc.setSquadsMultisig(map[solana.PublicKey]uint8{
+		voter.PublicKey():    squadsPermissionInitiate | squadsPermissionVote,
+		executor.PublicKey(): squadsPermissionVote | squadsPermissionExecute,
+	})
+
+	writeRole := func(data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/treasury",
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	t.Run("Reject Unknown Permission", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{
+			"bound_squads_multisig": multisig.String(),
+			"squads_permissions":    "vote,admin",
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Admit Any Member", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{"bound_squads_multisig": multisig.String()})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		for _, wallet := range []*solana.Wallet{voter, executor} {
+			resp, err := loginWithWallet(t, backend, storage, wallet, map[string]any{"role": "treasury"})
+
+			assert.NoError(t, err)
+			assert.NotNil(t, resp.Auth)
+		}
+
+		resp, err = loginWithWallet(t, backend, storage, outsider, map[string]any{"role": "treasury"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "not a member")
+	})
+
+	t.Run("Require Permissions", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{"squads_permissions": "Execute"})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		resp, err = loginWithWallet(t, backend, storage, executor, map[string]any{"role": "treasury"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+
+		resp, err = loginWithWallet(t, backend, storage, voter, map[string]any{"role": "treasury"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "missing required permissions")
+	})
+}
+
+func TestUpgradeAuthorityRole(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	rpc := configureFakeRPC(t, backend, storage)
+
+	deployer := solana.NewWallet()
+	successor := solana.NewWallet()
+
+	deployerKey := deployer.PublicKey()
+	program := rpc.setUpgradeableProgram(&deployerKey)
+	programData, _, _ := solana.FindProgramAddress([][]byte{program[:]}, solana.BPFLoaderUpgradeableProgramID)
+
+	resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/deploy",
+		Storage:   storage,
+		Data: map[string]any{
+			"bound_program_upgrade_authority": program.String(),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	t.Run("Admit Upgrade Authority", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := loginWithWallet(t, backend, storage, deployer, map[string]any{"role": "deploy"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+
+		resp, err = loginWithWallet(t, backend, storage, successor, map[string]any{"role": "deploy"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "not the upgrade authority")
+	})
+
+	t.Run("Follow Authority Transfer", func(t *testing.T) {
+		t.Helper()
+
+		successorKey := successor.PublicKey()
+		rpc.setUpgradeAuthority(programData, &successorKey)
+
+		resp, err := loginWithWallet(t, backend, storage, successor, map[string]any{"role": "deploy"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+
+		resp, err = loginWithWallet(t, backend, storage, deployer, map[string]any{"role": "deploy"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Deny Immutable Program", func(t *testing.T) {
+		t.Helper()
+
+		rpc.setUpgradeAuthority(programData, nil)
+
+		resp, err := loginWithWallet(t, backend, storage, successor, map[string]any{"role": "deploy"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "immutable")
+	})
+}
+
+func TestRenewalReevaluatesBindings(t *testing.T) {
+	backend, storage := getTestBackend(t)
+
+	holder := solana.NewWallet()
+	mint := solana.NewWallet().PublicKey()
+
+	rpc := configureFakeRPC(t, backend, storage)
+	account := rpc.setTokenAccount(holder.PublicKey(), mint, 1)
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/holders",
+		Storage:   storage,
+		Data: map[string]any{
+			"bound_token_mint": mint.String(),
+			"token_policies":   "holder",
+		},
+	})
+	assert.NoError(t, err)
+
+	resp, err := loginWithWallet(t, backend, storage, holder, map[string]any{"role": "holders"})
+	assert.NoError(t, err)
+
+	auth := resp.Auth
+	auth.TokenPolicies = auth.Policies
+
+	renew := func() (*logical.Response, error) {
+		req := logical.RenewAuthRequest("login", auth, nil)
+		req.Storage = storage
+		return backend.HandleRequest(context.Background(), req)
+	}
+
+	t.Run("Renew while Bindings Hold", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := renew()
+
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		assert.Equal(t, 2, rpc.callCount("getTokenAccountsByOwner"))
+	})
+
+	t.Run("Deny Renewal after Token is Sold", func(t *testing.T) {
+		t.Helper()
+
+		rpc.deleteAccount(account)
+
+		resp, err := renew()
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "renewal denied: balance of token")
+	})
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+var errShortAccountData = errors.New("account data is too short")
+
+// borshReader sequentially decodes the Borsh-serialized fields of program
+// account data. The first error encountered is sticky and reported by err.
+type borshReader struct {
+	data []byte
+	err  error
+}
+
+func (r *borshReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if n < 0 || len(r.data) < n {
+		r.err = errShortAccountData
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *borshReader) skip(n int) {
+	r.take(n)
+}
+
+func (r *borshReader) bool() bool {
+	return r.u8() != 0
+}
+
+func (r *borshReader) u8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *borshReader) u16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *borshReader) u32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *borshReader) u64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *borshReader) pubkey() solana.PublicKey {
+	if b := r.take(solana.PublicKeyLength); b != nil {
+		return solana.PublicKeyFromBytes(b)
+	}
+	return solana.PublicKey{}
+}
+
+func (r *borshReader) string() string {
+	return string(r.take(int(r.u32())))
+}
+
+func (r *borshReader) remaining() int {
+	return len(r.data)
+}
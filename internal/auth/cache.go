@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	maxCacheEntries = 10000
+)
+
+// ttlCache is a size-bounded map whose entries expire after a time-to-live.
+type ttlCache[V any] struct {
+	sync.Mutex
+
+	entries map[string]ttlCacheEntry[V]
+}
+
+type ttlCacheEntry[V any] struct {
+	expiresAt time.Time
+	value     V
+}
+
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		var zero V
+		return zero, false
+	}
+
+	return entry.value, true
+}
+
+func (c *ttlCache[V]) set(key string, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+
+	if c.entries == nil {
+		c.entries = make(map[string]ttlCacheEntry[V])
+	}
+
+	if len(c.entries) >= maxCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+
+		// Start over rather than grow without bound if nothing has expired.
+		if len(c.entries) >= maxCacheEntries {
+			clear(c.entries)
+		}
+	}
+
+	c.entries[key] = ttlCacheEntry[V]{expiresAt: now.Add(ttl), value: value}
+}
+
+func (c *ttlCache[V]) purge() {
+	c.Lock()
+	defer c.Unlock()
+
+	clear(c.entries)
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	defaultNonceTemplate = "vault:solana:{{.Nonce}}"
+)
+
+type challengeTemplateData struct {
+	Audience  string
+	ClusterID string
+	ExpiresAt string
+	IssuedAt  string
+	Mount     string
+	Nonce     string
+	PublicKey string
+	Role      string
+}
+
+func parseNonceTemplate(text string) (*template.Template, error) {
+	return template.New("nonce").Option("missingkey=error").Parse(text)
+}
+
+// validateNonceTemplate ensures the template renders and that its output
+// includes the random nonce, without which every challenge for a public key
+// would be identical and signatures could be replayed.
+func validateNonceTemplate(text string) error {
+	tmpl, err := parseNonceTemplate(text)
+	if err != nil {
+		return err
+	}
+
+	sample := challengeTemplateData{
+		Audience:  "audience",
+		ClusterID: "cluster",
+		ExpiresAt: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		IssuedAt:  time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Mount:     "auth/solana/",
+		Nonce:     "c2FtcGxlLW5vbmNl",
+		PublicKey: "11111111111111111111111111111111",
+		Role:      "role",
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, sample); err != nil {
+		return err
+	}
+
+	if !strings.Contains(b.String(), sample.Nonce) {
+		return errors.New("template must include {{.Nonce}}")
+	}
+
+	if !utf8.ValidString(b.String()) {
+		return errors.New("template must render valid UTF-8")
+	}
+
+	return nil
+}
+
+// issueChallenge renders the message that must be signed for a newly issued
+// nonce and records it in the entry, so that changes to the template or
+// audience do not invalidate nonces that are already outstanding.
+func (s *SolanaAuthBackend) issueChallenge(ctx context.Context, req *logical.Request, config *AuthConfigEntry, nonce *NonceEntry) (string, error) {
+	challenge, err := s.renderChallenge(ctx, req, config, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	nonce.Challenge = challenge
+	return challenge, nil
+}
+
+// challengeMessage returns the message that must be signed for the nonce,
+// as recorded when it was issued. Nonces persisted before challenges were
+// recorded are rendered from the current configuration instead.
+func (s *SolanaAuthBackend) challengeMessage(ctx context.Context, req *logical.Request, config *AuthConfigEntry, nonce *NonceEntry) (string, error) {
+	if nonce.Challenge != "" {
+		return nonce.Challenge, nil
+	}
+
+	return s.renderChallenge(ctx, req, config, nonce)
+}
+
+// renderChallenge renders the challenge template from the structured nonce
+// fields, so the signature is only valid for this mount, audience, public key
+// and validity window.
+func (s *SolanaAuthBackend) renderChallenge(ctx context.Context, req *logical.Request, config *AuthConfigEntry, nonce *NonceEntry) (string, error) {
+	text := config.NonceTemplate
+	if text == "" {
+		text = defaultNonceTemplate
+	}
+
+	tmpl, err := parseNonceTemplate(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid nonce template: %w", err)
+	}
+
+	clusterID, err := s.System().ClusterID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	data := challengeTemplateData{
+		Audience:  config.Audience,
+		ClusterID: clusterID,
+		ExpiresAt: time.Unix(nonce.ExpiresAt, 0).UTC().Format(time.RFC3339),
+		IssuedAt:  time.Unix(nonce.IssuedAt, 0).UTC().Format(time.RFC3339),
+		Mount:     req.MountPoint,
+		Nonce:     nonce.Nonce,
+		PublicKey: nonce.PublicKey,
+		Role:      nonce.Role,
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render nonce template: %w", err)
+	}
+
+	if !utf8.ValidString(b.String()) {
+		return "", errors.New("rendered nonce template is not valid UTF-8")
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gagliardetto/solana-go"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+	"github.com/callensm/vault-plugin-solana/internal/offchain"
+)
+
+var errCosignature = errors.New("co-signature verification failed")
+
+// requiresCosigner reports whether logins with the role must be approved by
+// one of its co-signer keys.
+func (r *RoleEntry) requiresCosigner() bool {
+	return len(r.CosignerPublicKeys) > 0
+}
+
+// verifyCosignature verifies that one of the role's co-signer keys, other
+// than the keys that performed the login, signed the same challenge as an
+// offchain message. errCosignature is returned if the signature is invalid.
+func verifyCosignature(role *RoleEntry, challenge, cosigner, encodedSig string, signers ...solana.PublicKey) error {
+	if cosigner == "" || encodedSig == "" {
+		return errors.New("role requires a co-signer public key and signature")
+	}
+
+	if !slices.Contains(role.CosignerPublicKeys, cosigner) {
+		return fmt.Errorf("co-signer %s is not permitted by the role", cosigner)
+	}
+
+	pk, err := parsePublicKey(cosigner)
+	if err != nil {
+		return fmt.Errorf("invalid co-signer public key: %w", err)
+	}
+
+	if slices.ContainsFunc(signers, pk.Equals) {
+		return errors.New("co-signer must differ from the signing key")
+	}
+
+	sig, err := message.ParseSignature(encodedSig)
+	if err != nil {
+		return err
+	}
+
+	msg, err := offchain.Message(challenge)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(pk[:]), msg, sig[:]) {
+		return errCosignature
+	}
+
+	return nil
+}
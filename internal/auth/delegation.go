@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+	"github.com/callensm/vault-plugin-solana/internal/offchain"
+)
+
+var errDelegationSignature = errors.New("delegation signature verification failed")
+
+// verifyDelegation verifies that the certificate was signed by the identity
+// key and authorizes a delegate key to login to the mount, returning the
+// delegation and its delegate key. errDelegationSignature is returned if the
+// identity key's signature is invalid.
+func verifyDelegation(identity solana.PublicKey, mount, certificate, encodedSig string, now time.Time) (*message.Delegation, solana.PublicKey, error) {
+	delegation, err := message.ParseDelegationMessage(certificate)
+	if err != nil {
+		return nil, solana.PublicKey{}, err
+	}
+
+	if delegation.Identity != identity.String() {
+		return nil, solana.PublicKey{}, errors.New("identity does not match public key")
+	}
+
+	delegate, err := parsePublicKey(delegation.Delegate)
+	if err != nil {
+		return nil, solana.PublicKey{}, errors.New("invalid delegate public key")
+	}
+
+	if delegation.Mount != mount {
+		return nil, solana.PublicKey{}, errors.New("delegation is for a different mount")
+	}
+
+	if !now.Before(delegation.ExpiresAt) {
+		return nil, solana.PublicKey{}, errors.New("delegation expired")
+	}
+
+	if encodedSig == "" {
+		return nil, solana.PublicKey{}, errors.New("missing delegation signature")
+	}
+
+	sig, err := message.ParseSignature(encodedSig)
+	if err != nil {
+		return nil, solana.PublicKey{}, err
+	}
+
+	msg, err := offchain.Message(certificate)
+	if err != nil {
+		return nil, solana.PublicKey{}, err
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(identity[:]), msg, sig[:]) {
+		return nil, solana.PublicKey{}, errDelegationSignature
+	}
+
+	return delegation, delegate, nil
+}
+
+// capDelegatedTTL limits a token TTL so that it does not outlive the
+// delegation it was issued under.
+func capDelegatedTTL(ttl time.Duration, expiresAt, now time.Time) time.Duration {
+	remaining := expiresAt.Sub(now)
+	if ttl == 0 || ttl > remaining {
+		return remaining
+	}
+	return ttl
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	defaultGovernanceProgramID = "GovER5Lthms3bLBqWub97yVrMmEogzX7xNjdXpPPCVZw"
+
+	tokenOwnerRecordV1 = 2
+	tokenOwnerRecordV2 = 17
+)
+
+// tokenOwnerRecord holds the leading fields of an SPL Governance
+// TokenOwnerRecord account.
+type tokenOwnerRecord struct {
+	DepositAmount       uint64
+	GoverningTokenMint  solana.PublicKey
+	GoverningTokenOwner solana.PublicKey
+	Realm               solana.PublicKey
+}
+
+func decodeTokenOwnerRecord(data []byte) (*tokenOwnerRecord, error) {
+	r := &borshReader{data: data}
+
+	if accountType := r.u8(); r.err == nil && accountType != tokenOwnerRecordV1 && accountType != tokenOwnerRecordV2 {
+		return nil, fmt.Errorf("unexpected governance account type %d", accountType)
+	}
+
+	record := &tokenOwnerRecord{
+		Realm:               r.pubkey(),
+		GoverningTokenMint:  r.pubkey(),
+		GoverningTokenOwner: r.pubkey(),
+		DepositAmount:       r.u64(),
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return record, nil
+}
+
+// checkGovernanceMembership requires the owner to have deposited at least
+// min governing tokens of the mint into the realm.
+func checkGovernanceMembership(ctx context.Context, client rpcClient, owner, program, realm, mint solana.PublicKey, min uint64) error {
+	address, _, err := solana.FindProgramAddress([][]byte{
+		[]byte("governance"),
+		realm[:],
+		mint[:],
+		owner[:],
+	}, program)
+	if err != nil {
+		return err
+	}
+
+	account, err := client.getAccountInfo(ctx, address)
+	if err != nil {
+		return fmt.Errorf("failed to fetch token owner record: %w", err)
+	}
+
+	if account == nil || !account.Owner.Equals(program) {
+		return &deniedError{fmt.Sprintf("no token owner record in governance realm %s", realm)}
+	}
+
+	record, err := decodeTokenOwnerRecord(account.Data)
+	if err != nil {
+		return fmt.Errorf("failed to decode token owner record: %w", err)
+	}
+
+	if !record.Realm.Equals(realm) || !record.GoverningTokenMint.Equals(mint) || !record.GoverningTokenOwner.Equals(owner) {
+		return &deniedError{fmt.Sprintf("no token owner record in governance realm %s", realm)}
+	}
+
+	if record.DepositAmount < min {
+		return &deniedError{fmt.Sprintf("governing token deposit in realm %s is below the required %d", realm, min)}
+	}
+
+	return nil
+}
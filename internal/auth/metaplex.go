@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	metadataAccountKey = 4
+)
+
+// metadataCollection is the collection of a Metaplex metadata account.
+type metadataCollection struct {
+	Key      solana.PublicKey
+	Verified bool
+}
+
+// decodeMetadataCollection decodes a Metaplex token metadata account,
+// returning its mint and its collection, if any.
+func decodeMetadataCollection(data []byte) (solana.PublicKey, *metadataCollection, error) {
+	r := &borshReader{data: data}
+
+	if r.u8() != metadataAccountKey {
+		return solana.PublicKey{}, nil, errors.New("not a metadata account")
+	}
+
+	r.skip(solana.PublicKeyLength) // update authority
+	mint := r.pubkey()
+
+	r.string() // name
+	r.string() // symbol
+	r.string() // uri
+	r.u16()    // seller fee basis points
+
+	if r.bool() {
+		creators := int(r.u32())
+		r.skip(creators * (solana.PublicKeyLength + 2))
+	}
+
+	r.bool() // primary sale happened
+	r.bool() // is mutable
+
+	if r.err != nil {
+		return solana.PublicKey{}, nil, r.err
+	}
+
+	// Metadata written by older versions of the program ends here.
+	if r.remaining() == 0 {
+		return mint, nil, nil
+	}
+
+	if r.bool() {
+		r.u8() // edition nonce
+	}
+
+	if r.bool() {
+		r.u8() // token standard
+	}
+
+	if !r.bool() {
+		return mint, nil, r.err
+	}
+
+	collection := &metadataCollection{
+		Verified: r.bool(),
+		Key:      r.pubkey(),
+	}
+
+	if r.err != nil {
+		return solana.PublicKey{}, nil, r.err
+	}
+
+	return mint, collection, nil
+}
+
+// checkNFTCollection requires the owner to hold an NFT that is a verified
+// member of the Metaplex collection.
+func checkNFTCollection(ctx context.Context, client rpcClient, owner, collection solana.PublicKey) error {
+	var mints []solana.PublicKey
+
+	for _, program := range []solana.PublicKey{solana.TokenProgramID, solana.Token2022ProgramID} {
+		accounts, err := client.getTokenAccountsByOwner(ctx, owner, rpcTokenFilter{ProgramID: program})
+		if err != nil {
+			return fmt.Errorf("failed to fetch token accounts: %w", err)
+		}
+
+		for _, account := range accounts {
+			token, ok := decodeTokenAccount(account.Account)
+			if ok && token.Owner.Equals(owner) && token.Amount == 1 {
+				mints = append(mints, token.Mint)
+			}
+		}
+	}
+
+	addresses := make([]solana.PublicKey, len(mints))
+	for i, mint := range mints {
+		address, _, err := solana.FindTokenMetadataAddress(mint)
+		if err != nil {
+			return err
+		}
+		addresses[i] = address
+	}
+
+	// Metadata accounts change rarely, so they benefit most from the
+	// account cache when one is configured.
+	metadata, err := client.getMultipleAccounts(ctx, addresses)
+	if err != nil {
+		return fmt.Errorf("failed to fetch metadata accounts: %w", err)
+	}
+
+	for i, account := range metadata {
+		if account == nil || !account.Owner.Equals(solana.TokenMetadataProgramID) {
+			continue
+		}
+
+		mint, c, err := decodeMetadataCollection(account.Data)
+		if err != nil || !mint.Equals(mints[i]) {
+			continue
+		}
+
+		if c != nil && c.Verified && c.Key.Equals(collection) {
+			return nil
+		}
+	}
+
+	return &deniedError{fmt.Sprintf("no verified NFT of collection %s is held", collection)}
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/tokenutil"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+)
+
+const (
+	multisigNonceStoragePrefix = "multisig_nonce/"
+	multisigNonceStorageFormat = multisigNonceStoragePrefix + "%s/%s"
+)
+
+// isMultisig reports whether logins with the role must be signed by a
+// threshold of its bound public keys rather than by any one of them.
+func (r *RoleEntry) isMultisig() bool {
+	return r.SignatureThreshold > 0
+}
+
+func (r *RoleEntry) validateSignatureThreshold() error {
+	switch {
+	case r.SignatureThreshold < 0:
+		return errors.New("signature_threshold cannot be negative")
+	case r.SignatureThreshold == 0:
+		return nil
+	case r.hasOnChainBindings():
+		return errors.New("signature_threshold cannot be combined with on-chain bindings")
+	case r.SignatureThreshold > len(slices.Compact(slices.Sorted(slices.Values(r.BoundPublicKeys)))):
+		return errors.New("signature_threshold cannot exceed the number of distinct bound public keys")
+	}
+
+	return nil
+}
+
+// boundSigners returns the sorted public keys that have signed and are still
+// bound to the role.
+func (r *RoleEntry) boundSigners(signers []string) []string {
+	var bound []string
+	for _, key := range signers {
+		if slices.Contains(r.BoundPublicKeys, key) && !slices.Contains(bound, key) {
+			bound = append(bound, key)
+		}
+	}
+
+	slices.Sort(bound)
+	return bound
+}
+
+// issueMultisigNonce issues a single challenge for a multi-signature role,
+// which each member then signs and submits to login independently.
+func (s *SolanaAuthBackend) issueMultisigNonce(ctx context.Context, req *logical.Request, data *framework.FieldData, config *AuthConfigEntry, roleName string, role *RoleEntry) (*logical.Response, error) {
+	// Only members may request a nonce, and each is limited separately so
+	// that no one can exhaust the nonces available to the others.
+	pubkey := data.Get("public_key").(string)
+	if !slices.Contains(role.BoundPublicKeys, pubkey) {
+		return logical.ErrorResponse("invalid role or public key"), nil
+	}
+
+	if resp, err := s.limitNonceRequests(req, config, pubkey); resp != nil || err != nil {
+		return resp, err
+	}
+
+	if mode := data.Get("mode").(string); mode != loginModeOffchain {
+		return logical.ErrorResponse("multi-signature roles only support the %s login mode", loginModeOffchain), nil
+	}
+
+	// Members collect their signatures over the same nonce, so it is always
+	// kept in storage even when stateless nonces are enabled.
+	prefix := multisigNonceStoragePrefix + roleName + "/"
+
+	if err := s.evictMultisigNonces(ctx, req.Storage, prefix, pubkey, config.MaxNoncesPerKey-1); err != nil {
+		return nil, err
+	}
+
+	nonce, ttl, err := newNonceEntry(config, role, roleName, pubkey, loginModeOffchain)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.issueChallenge(ctx, req, config, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := putMultisigNonce(ctx, req.Storage, nonce); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]any{
+			"expires_at":          nonce.ExpiresAt,
+			"nonce":               challenge,
+			"nonce_id":            nonce.ID,
+			"signature_threshold": role.SignatureThreshold,
+			"ttl":                 int64(ttl.Seconds()),
+		},
+	}, nil
+}
+
+// evictMultisigNonces prunes the expired nonces of a multi-signature role and
+// evicts the oldest of those requested by the member until at most keep
+// remain, holding the lock that guards the signatures collected on them.
+func (s *SolanaAuthBackend) evictMultisigNonces(ctx context.Context, store logical.Storage, prefix, pubkey string, keep int) error {
+	s.multisigLock.Lock()
+	defer s.multisigLock.Unlock()
+
+	outstanding, err := s.pruneNonces(ctx, store, prefix, pubkey)
+	if err != nil {
+		return err
+	}
+
+	return evictOldestNonces(ctx, store, prefix, outstanding, keep)
+}
+
+// multisigLogin records a member's signature over the nonce of a
+// multi-signature role, issuing a token once the role's threshold of
+// distinct members have signed it.
+func (s *SolanaAuthBackend) multisigLogin(ctx context.Context, req *logical.Request, data *framework.FieldData, config *AuthConfigEntry, roleName string, role *RoleEntry) (*logical.Response, error) {
+	pubkey := data.Get("public_key").(string)
+	nonceID := data.Get("nonce_id").(string)
+
+	if data.Get("delegation").(string) != "" {
+		return logical.ErrorResponse("delegated login is not supported by multi-signature roles"), nil
+	}
+
+	if !slices.Contains(role.BoundPublicKeys, pubkey) {
+		return logical.ErrorResponse("invalid role or public key"), nil
+	}
+
+	pk, err := parsePublicKey(pubkey)
+	if err != nil {
+		return logical.ErrorResponse("invalid public key"), nil
+	}
+
+	signature, ok := data.Get("signature").(string)
+	if !ok || signature == "" {
+		return logical.ErrorResponse("missing or empty signature"), nil
+	}
+
+	sig, err := message.ParseSignature(signature)
+	if err != nil {
+		return logical.ErrorResponse("invalid signature: %v", err), nil
+	}
+
+	// Serialize updates to the collected signatures so that concurrent
+	// submissions by different members are not lost.
+	s.multisigLock.Lock()
+	defer s.multisigLock.Unlock()
+
+	nonce, err := getMultisigNonce(ctx, req.Storage, roleName, nonceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if nonce == nil {
+		return logical.ErrorResponse("nonce not found"), nil
+	}
+
+	storageKey := fmt.Sprintf(multisigNonceStorageFormat, roleName, nonce.ID)
+
+	if nonce.isExpired(time.Now().Unix()) {
+		if err := req.Storage.Delete(ctx, storageKey); err != nil {
+			return nil, err
+		}
+		return logical.ErrorResponse("nonce expired"), nil
+	}
+
+	if resp, err := s.verifyChallengeSignature(ctx, req, config, nonce, pubkey, pk, sig, true); resp != nil || err != nil {
+		return resp, err
+	}
+
+	if nonce.Signatures == nil {
+		nonce.Signatures = make(map[string]string)
+	}
+	nonce.Signatures[pubkey] = sig.String()
+
+	signers := role.boundSigners(slices.Collect(maps.Keys(nonce.Signatures)))
+
+	if len(signers) < role.SignatureThreshold {
+		if err := putMultisigNonce(ctx, req.Storage, nonce); err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]any{
+				"signature_threshold": role.SignatureThreshold,
+				"signers":             signers,
+			},
+		}, nil
+	}
+
+	if err := req.Storage.Delete(ctx, storageKey); err != nil {
+		return nil, err
+	}
+
+	auth := &logical.Auth{
+		InternalData: map[string]any{
+			"role":    roleName,
+			"signers": strings.Join(signers, ","),
+		},
+		Metadata: map[string]string{
+			"role":    roleName,
+			"signers": strings.Join(signers, ","),
+		},
+		DisplayName: fmt.Sprintf("solana-%s", roleName),
+		Alias: &logical.Alias{
+			// Base-58 public keys never contain a slash, so role aliases
+			// cannot collide with those of individual wallets.
+			Name: "role/" + roleName,
+			Metadata: map[string]string{
+				"role": roleName,
+			},
+		},
+	}
+	role.PopulateTokenAuth(auth)
+
+	return &logical.Response{
+		Auth: auth,
+	}, nil
+}
+
+// multisigTokenParams returns the token parameters of a multi-signature role
+// for a token issued to the signers. A deniedError is returned if the signers
+// no longer meet the role's threshold.
+func (s *SolanaAuthBackend) multisigTokenParams(ctx context.Context, store logical.Storage, roleName string, signers []string) (*tokenutil.TokenParams, error) {
+	role, err := s.getRole(ctx, store, roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil || !role.isMultisig() {
+		return nil, &deniedError{"invalid role"}
+	}
+
+	if len(role.boundSigners(signers)) < role.SignatureThreshold {
+		return nil, &deniedError{"signers no longer meet the signature threshold"}
+	}
+
+	return &role.TokenParams, nil
+}
+
+func getMultisigNonce(ctx context.Context, store logical.Storage, roleName, id string) (*NonceEntry, error) {
+	entry, err := store.Get(ctx, fmt.Sprintf(multisigNonceStorageFormat, roleName, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var nonce NonceEntry
+	if err := entry.DecodeJSON(&nonce); err != nil {
+		return nil, err
+	}
+
+	return &nonce, nil
+}
+
+func putMultisigNonce(ctx context.Context, store logical.Storage, nonce *NonceEntry) error {
+	entry, err := logical.StorageEntryJSON(fmt.Sprintf(multisigNonceStorageFormat, nonce.Role, nonce.ID), nonce)
+	if err != nil {
+		return err
+	}
+
+	return store.Put(ctx, entry)
+}
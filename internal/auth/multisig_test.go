@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+)
+
+func TestMultisigLogin(t *testing.T) {
+	backend, storage := getTestBackend(t)
+
+	members := []*solana.Wallet{solana.NewWallet(), solana.NewWallet(), solana.NewWallet()}
+	outsider := solana.NewWallet()
+
+	writeRole := func(data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/team",
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	nonceRequest := func(wallet *solana.Wallet) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"role":       "team",
+			},
+		})
+	}
+
+	requestNonce := func() (string, string) {
+		resp, err := nonceRequest(members[0])
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+
+		return resp.Data["nonce_id"].(string), resp.Data["nonce"].(string)
+	}
+
+	submit := func(wallet *solana.Wallet, nonceID, challenge string) (*logical.Response, error) {
+		sig, err := wallet.PrivateKey.Sign(message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			MessageBody: []byte(challenge),
+			Version:     0,
+		}))
+		assert.NoError(t, err)
+
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"nonce_id":   nonceID,
+				"public_key": wallet.PublicKey().String(),
+				"role":       "team",
+				"signature":  sig.String(),
+			},
+		})
+	}
+
+	t.Run("Validate Threshold", func(t *testing.T) {
+		t.Helper()
+
+		for _, data := range []map[string]any{
+			{"bound_public_keys": members[0].PublicKey().String(), "signature_threshold": 2},
+			{"bound_public_keys": []string{members[0].PublicKey().String(), members[0].PublicKey().String()}, "signature_threshold": 2},
+			{"bound_public_keys": members[0].PublicKey().String(), "signature_threshold": -1},
+			{"bound_public_keys": members[0].PublicKey().String(), "signature_threshold": 1, "bound_token_mint": solana.NewWallet().PublicKey().String()},
+		} {
+			resp, err := writeRole(data)
+
+			assert.NoError(t, err)
+			assert.True(t, resp.IsError(), data)
+		}
+	})
+
+	resp, err := writeRole(map[string]any{
+		"bound_public_keys": []string{
+			members[0].PublicKey().String(),
+			members[1].PublicKey().String(),
+			members[2].PublicKey().String(),
+		},
+		"signature_threshold": 2,
+		"token_policies":      "team",
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	t.Run("Login after Threshold is Reached", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, challenge := requestNonce()
+
+		resp, err := submit(members[0], nonceID, challenge)
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		assert.Nil(t, resp.Auth)
+		assert.Equal(t, []string{members[0].PublicKey().String()}, resp.Data["signers"])
+
+		// Signing again does not count towards the threshold.
+		resp, err = submit(members[0], nonceID, challenge)
+		assert.NoError(t, err)
+		assert.Nil(t, resp.Auth)
+
+		resp, err = submit(members[2], nonceID, challenge)
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+		assert.Equal(t, "role/team", resp.Auth.Alias.Name)
+		assert.Contains(t, resp.Auth.Policies, "team")
+		assert.Contains(t, resp.Auth.Metadata["signers"], members[0].PublicKey().String())
+		assert.Contains(t, resp.Auth.Metadata["signers"], members[2].PublicKey().String())
+
+		resp, err = submit(members[1], nonceID, challenge)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "nonce not found")
+	})
+
+	t.Run("Reject Non-Members and Invalid Signatures", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, challenge := requestNonce()
+
+		resp, err := submit(outsider, nonceID, challenge)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "invalid role or public key")
+
+		resp, err = submit(members[0], nonceID, "some other message")
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), loginFailedMessage)
+
+		nonce, err := getMultisigNonce(context.Background(), storage, "team", nonceID)
+		assert.NoError(t, err)
+		assert.Empty(t, nonce.Signatures)
+	})
+
+	t.Run("Reject Other Login Modes", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"mode":       loginModeTransaction,
+				"public_key": members[0].PublicKey().String(),
+				"role":       "team",
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Evict Outstanding Nonces per Member", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"max_nonces_per_key": 2,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		for range 3 {
+			resp, err = nonceRequest(outsider)
+			assert.NoError(t, err)
+			assert.ErrorContains(t, resp.Error(), "invalid role or public key")
+		}
+
+		other, err := nonceRequest(members[2])
+		assert.NoError(t, err)
+
+		var nonceIDs []string
+		for range 3 {
+			resp, err = nonceRequest(members[1])
+			assert.NoError(t, err)
+			assert.False(t, resp.IsError())
+
+			nonceIDs = append(nonceIDs, resp.Data["nonce_id"].(string))
+		}
+
+		// Only the oldest nonce of the member is evicted.
+		for i, nonceID := range nonceIDs {
+			nonce, err := getMultisigNonce(context.Background(), storage, "team", nonceID)
+			assert.NoError(t, err)
+			assert.Equal(t, i > 0, nonce != nil, i)
+		}
+
+		nonce, err := getMultisigNonce(context.Background(), storage, "team", other.Data["nonce_id"].(string))
+		assert.NoError(t, err)
+		assert.NotNil(t, nonce)
+	})
+
+	t.Run("Deny Renewal below Threshold", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, challenge := requestNonce()
+
+		_, err := submit(members[0], nonceID, challenge)
+		assert.NoError(t, err)
+
+		resp, err := submit(members[1], nonceID, challenge)
+		assert.NoError(t, err)
+
+		auth := resp.Auth
+		auth.TokenPolicies = auth.Policies
+
+		renew := func() (*logical.Response, error) {
+			req := logical.RenewAuthRequest("login", auth, nil)
+			req.Storage = storage
+			return backend.HandleRequest(context.Background(), req)
+		}
+
+		resp, err = renew()
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+
+		_, err = writeRole(map[string]any{
+			"bound_public_keys": []string{
+				members[0].PublicKey().String(),
+				members[2].PublicKey().String(),
+			},
+		})
+		assert.NoError(t, err)
+
+		resp, err = renew()
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "renewal denied")
+	})
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	nonceKeyStorageKey = "nonce_key"
+	nonceTokenSep      = "."
+
+	// maxUsedNonces bounds the memory held by redeemed stateless nonces.
+	maxUsedNonces = 100000
+)
+
+var (
+	errInvalidNonceToken  = errors.New("invalid nonce token")
+	errUsedNonceCacheFull = errors.New("too many stateless nonces redeemed recently, try again later")
+)
+
+type nonceKeyEntry struct {
+	Key []byte `json:"key"`
+}
+
+// usedNonceCache remembers the identifiers of stateless nonces that have
+// already been redeemed until they expire, preventing their replay. Expired
+// entries are pruned by the periodic function, and the cache refuses new
+// entries once it holds maxUsedNonces unexpired ones.
+type usedNonceCache struct {
+	sync.Mutex
+
+	entries map[string]int64
+}
+
+// markUsed records the nonce as redeemed, returning false if it already was.
+func (c *usedNonceCache) markUsed(id string, expiresAt int64) (bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]int64)
+	}
+
+	if _, ok := c.entries[id]; ok {
+		return false, nil
+	}
+
+	if len(c.entries) >= maxUsedNonces {
+		c.pruneLocked(time.Now().Unix())
+
+		if len(c.entries) >= maxUsedNonces {
+			return false, errUsedNonceCacheFull
+		}
+	}
+
+	c.entries[id] = expiresAt
+	return true, nil
+}
+
+// prune removes the entries of nonces that have expired, since they can no
+// longer be redeemed anyway.
+func (c *usedNonceCache) prune(now int64) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.pruneLocked(now)
+}
+
+func (c *usedNonceCache) pruneLocked(now int64) {
+	for id, exp := range c.entries {
+		if now > exp {
+			delete(c.entries, id)
+		}
+	}
+}
+
+func isNonceToken(nonceID string) bool {
+	return strings.Contains(nonceID, nonceTokenSep)
+}
+
+func (s *SolanaAuthBackend) getNonceKey(ctx context.Context, store logical.Storage) ([]byte, error) {
+	entry, err := store.Get(ctx, nonceKeyStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key nonceKeyEntry
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+
+	return key.Key, nil
+}
+
+// ensureNonceKey returns the stateless nonce signing key, generating it if
+// it does not exist yet. The key is kept in mount-local storage, so it is
+// generated separately by each cluster the mount is replicated to.
+func (s *SolanaAuthBackend) ensureNonceKey(ctx context.Context, store logical.Storage) ([]byte, error) {
+	s.nonceKeyLock.Lock()
+	defer s.nonceKeyLock.Unlock()
+
+	key, err := s.getNonceKey(ctx, store)
+	if err != nil || key != nil {
+		return key, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce key: %w", err)
+	}
+
+	entry, err := logical.StorageEntryJSON(nonceKeyStorageKey, &nonceKeyEntry{Key: key})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func signNonceToken(key []byte, nonce *NonceEntry) (string, error) {
+	payload, err := json.Marshal(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + nonceTokenSep + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func parseNonceToken(key []byte, token string) (*NonceEntry, error) {
+	encodedPayload, encodedMac, ok := strings.Cut(token, nonceTokenSep)
+	if !ok {
+		return nil, errInvalidNonceToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, errInvalidNonceToken
+	}
+
+	tokenMac, err := base64.RawURLEncoding.DecodeString(encodedMac)
+	if err != nil {
+		return nil, errInvalidNonceToken
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+
+	if !hmac.Equal(tokenMac, mac.Sum(nil)) {
+		return nil, errInvalidNonceToken
+	}
+
+	var nonce NonceEntry
+	if err := json.Unmarshal(payload, &nonce); err != nil {
+		return nil, errInvalidNonceToken
+	}
+
+	return &nonce, nil
+}
@@ -2,36 +2,134 @@ package auth
 
 import (
 	"context"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/tokenutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
 const (
-	configStorageKey   = "config"
-	defaultTokenTtl    = 3600
-	defaultTokenMaxTtl = 86400
+	configStorageKey            = "config"
+	defaultLockoutCounterReset  = 15 * time.Minute
+	defaultLockoutDuration      = 15 * time.Minute
+	defaultLockoutThreshold     = 0
+	defaultMaxNoncesPerKey      = 5
+	defaultMaxPendingPublicKeys = 10000
+	defaultNonceClientRateLimit = 5
+	defaultNonceClientRateBurst = 20
+	defaultNonceRateLimit       = 0
+	defaultNonceRateBurst       = 5
+	defaultNonceTTL             = 5 * time.Minute
+	defaultTokenTtl             = time.Hour
+	defaultTokenMaxTtl          = 24 * time.Hour
 )
 
 func pathConfig(s *SolanaAuthBackend) *framework.Path {
+	fields := map[string]*framework.FieldSchema{
+		"allow_roleless_login": {
+			Type:        framework.TypeBool,
+			Description: "Whether wallets may log in without a role, receiving the token parameters of this configuration; any wallet able to sign a nonce is then issued a token",
+		},
+		"audience": {
+			Type:        framework.TypeString,
+			Description: "Audience or domain the challenge messages are intended for, available to the nonce template as {{.Audience}}",
+		},
+		"clock_skew_leeway": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Additional duration past the expiry of a nonce during which it is still accepted, to tolerate clock skew",
+		},
+		"lockout_counter_reset": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Duration after the last failed login at which the failure counter of a public key resets",
+			Default:     int(defaultLockoutCounterReset.Seconds()),
+		},
+		"lockout_duration": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Duration for which a public key is locked out once the threshold is reached",
+			Default:     int(defaultLockoutDuration.Seconds()),
+		},
+		"lockout_threshold": {
+			Type:        framework.TypeInt,
+			Description: "Number of failed logins after which a public key is locked out; 0 (the default) disables lockout",
+			Default:     defaultLockoutThreshold,
+		},
+		"max_nonces_per_key": {
+			Type:        framework.TypeInt,
+			Description: "Maximum number of outstanding nonces a single public key may hold at once",
+			Default:     defaultMaxNoncesPerKey,
+		},
+		"max_pending_public_keys": {
+			Type:        framework.TypeInt,
+			Description: "Maximum number of distinct public keys that may hold outstanding nonces at once",
+			Default:     defaultMaxPendingPublicKeys,
+		},
+		"nonce_client_rate_burst": {
+			Type:        framework.TypeInt,
+			Description: "Number of nonce and login session requests permitted in a burst for each client address",
+			Default:     defaultNonceClientRateBurst,
+		},
+		"nonce_client_rate_limit": {
+			Type:        framework.TypeFloat,
+			Description: "Nonce and login session requests per second permitted for each client address; 0 disables rate limiting by address",
+			Default:     defaultNonceClientRateLimit,
+		},
+		"nonce_rate_limit": {
+			Type:        framework.TypeFloat,
+			Description: "Nonce requests per second permitted for each public key; 0 (the default) disables rate limiting by public key",
+			Default:     defaultNonceRateLimit,
+		},
+		"nonce_template": {
+			Type:        framework.TypeString,
+			Description: "Go template of the challenge message to be signed; may reference {{.Nonce}}, {{.PublicKey}}, {{.Role}}, {{.Mount}}, {{.ClusterID}}, {{.Audience}}, {{.IssuedAt}} and {{.ExpiresAt}}",
+			Default:     defaultNonceTemplate,
+		},
+		"nonce_ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Duration for which an issued nonce may be used to login",
+			Default:     int(defaultNonceTTL.Seconds()),
+		},
+		"nonce_rate_burst": {
+			Type:        framework.TypeInt,
+			Description: "Number of nonce requests permitted in a burst for each public key",
+			Default:     defaultNonceRateBurst,
+		},
+		"siws_chain_id": {
+			Type:        framework.TypeString,
+			Description: "Chain ID included in Sign-In With Solana messages, such as mainnet or devnet",
+		},
+		"siws_domain": {
+			Type:        framework.TypeString,
+			Description: "Domain requesting Sign-In With Solana messages; required to enable the siws login mode",
+		},
+		"siws_statement": {
+			Type:        framework.TypeString,
+			Description: "Human-readable statement included in Sign-In With Solana messages",
+		},
+		"siws_uri": {
+			Type:        framework.TypeString,
+			Description: "URI included in Sign-In With Solana messages",
+		},
+		"session_base_url": {
+			Type:        framework.TypeString,
+			Description: "Externally reachable Vault address used to build the signature URLs and deep links of cross-device login sessions; required to enable login sessions",
+		},
+		"session_icon_url": {
+			Type:        framework.TypeString,
+			Description: "URL of an SVG, PNG or WebP icon shown by wallets that open the deep link of a login session",
+		},
+		"stateless_nonces": {
+			Type:        framework.TypeBool,
+			Description: "Whether to issue self-contained HMAC-signed nonces instead of persisting them to storage",
+		},
+	}
+	tokenutil.AddTokenFields(fields)
+
 	return &framework.Path{
 		Pattern: "config",
-		Fields: map[string]*framework.FieldSchema{
-			"token_policies": {
-				Type:        framework.TypeCommaStringSlice,
-				Description: "Comma-separated list of policies to attach to tokens",
-			},
-			"token_ttl": {
-				Type:        framework.TypeDurationSecond,
-				Description: "Default TTL for tokens issued",
-				Default:     defaultTokenTtl,
-			},
-			"token_max_ttl": {
-				Type:        framework.TypeDurationSecond,
-				Description: "Maximum TTL for tokens issued",
-				Default:     defaultTokenMaxTtl,
-			},
-		},
+		Fields:  fields,
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.CreateOperation: &framework.PathOperation{
 				Callback: s.pathConfigWrite,
@@ -64,20 +162,196 @@ func (s *SolanaAuthBackend) pathConfigRead(ctx context.Context, req *logical.Req
 		return nil, err
 	}
 
+	respData := map[string]any{
+		"allow_roleless_login":    config.AllowRolelessLogin,
+		"audience":                config.Audience,
+		"clock_skew_leeway":       int64(config.ClockSkewLeeway.Seconds()),
+		"lockout_counter_reset":   int64(config.LockoutCounterReset.Seconds()),
+		"lockout_duration":        int64(config.LockoutDuration.Seconds()),
+		"lockout_threshold":       config.LockoutThreshold,
+		"max_nonces_per_key":      config.MaxNoncesPerKey,
+		"max_pending_public_keys": config.MaxPendingPublicKeys,
+		"nonce_client_rate_burst": config.NonceClientRateBurst,
+		"nonce_client_rate_limit": config.NonceClientRateLimit,
+		"nonce_rate_limit":        config.NonceRateLimit,
+		"nonce_rate_burst":        config.NonceRateBurst,
+		"nonce_template":          config.NonceTemplate,
+		"nonce_ttl":               int64(config.NonceTTL.Seconds()),
+		"siws_chain_id":           config.SIWSChainID,
+		"siws_domain":             config.SIWSDomain,
+		"siws_statement":          config.SIWSStatement,
+		"siws_uri":                config.SIWSURI,
+		"session_base_url":        config.SessionBaseURL,
+		"session_icon_url":        config.SessionIconURL,
+		"stateless_nonces":        config.StatelessNonces,
+	}
+	config.PopulateTokenData(respData)
+
 	return &logical.Response{
-		Data: map[string]any{
-			"token_ttl":      config.TokenTtl,
-			"token_max_ttl":  config.TokenMaxTtl,
-			"token_policies": config.TokenPolicies,
-		},
+		Data: respData,
 	}, nil
 }
 
 func (s *SolanaAuthBackend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	config := &AuthConfigEntry{
-		TokenTtl:      data.Get("token_ttl").(int),
-		TokenMaxTtl:   data.Get("token_max_ttl").(int),
-		TokenPolicies: data.Get("token_policies").([]string),
+	config, err := s.getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.ParseTokenFields(req, data); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if raw, ok := data.GetOk("allow_roleless_login"); ok {
+		config.AllowRolelessLogin = raw.(bool)
+	}
+
+	if raw, ok := data.GetOk("audience"); ok {
+		config.Audience = raw.(string)
+	}
+
+	if raw, ok := data.GetOk("clock_skew_leeway"); ok {
+		config.ClockSkewLeeway = time.Duration(raw.(int)) * time.Second
+	}
+
+	if raw, ok := data.GetOk("lockout_counter_reset"); ok {
+		config.LockoutCounterReset = time.Duration(raw.(int)) * time.Second
+	}
+
+	if raw, ok := data.GetOk("lockout_duration"); ok {
+		config.LockoutDuration = time.Duration(raw.(int)) * time.Second
+	}
+
+	if raw, ok := data.GetOk("lockout_threshold"); ok {
+		config.LockoutThreshold = raw.(int)
+	}
+
+	if config.LockoutThreshold < 0 {
+		return logical.ErrorResponse("lockout_threshold cannot be negative"), nil
+	}
+
+	if config.LockoutThreshold > 0 && (config.LockoutDuration <= 0 || config.LockoutCounterReset <= 0) {
+		return logical.ErrorResponse("lockout_duration and lockout_counter_reset must be positive when lockout is enabled"), nil
+	}
+
+	if raw, ok := data.GetOk("max_nonces_per_key"); ok {
+		config.MaxNoncesPerKey = raw.(int)
+	}
+
+	if config.MaxNoncesPerKey < 1 {
+		return logical.ErrorResponse("max_nonces_per_key must be at least 1"), nil
+	}
+
+	if raw, ok := data.GetOk("max_pending_public_keys"); ok {
+		config.MaxPendingPublicKeys = raw.(int)
+	}
+
+	if config.MaxPendingPublicKeys < 1 {
+		return logical.ErrorResponse("max_pending_public_keys must be at least 1"), nil
+	}
+
+	if raw, ok := data.GetOk("nonce_client_rate_limit"); ok {
+		config.NonceClientRateLimit = raw.(float64)
+	}
+
+	if raw, ok := data.GetOk("nonce_client_rate_burst"); ok {
+		config.NonceClientRateBurst = raw.(int)
+	}
+
+	if config.NonceClientRateLimit < 0 {
+		return logical.ErrorResponse("nonce_client_rate_limit cannot be negative"), nil
+	}
+
+	if config.NonceClientRateLimit > 0 && config.NonceClientRateBurst < 1 {
+		return logical.ErrorResponse("nonce_client_rate_burst must be at least 1 when rate limiting by address is enabled"), nil
+	}
+
+	if raw, ok := data.GetOk("nonce_rate_limit"); ok {
+		config.NonceRateLimit = raw.(float64)
+	}
+
+	if raw, ok := data.GetOk("nonce_rate_burst"); ok {
+		config.NonceRateBurst = raw.(int)
+	}
+
+	if config.NonceRateLimit < 0 {
+		return logical.ErrorResponse("nonce_rate_limit cannot be negative"), nil
+	}
+
+	if config.NonceRateLimit > 0 && config.NonceRateBurst < 1 {
+		return logical.ErrorResponse("nonce_rate_burst must be at least 1 when rate limiting is enabled"), nil
+	}
+
+	if raw, ok := data.GetOk("nonce_template"); ok {
+		config.NonceTemplate = raw.(string)
+	}
+
+	if err := validateNonceTemplate(config.NonceTemplate); err != nil {
+		return logical.ErrorResponse("invalid nonce_template: %v", err), nil
+	}
+
+	if raw, ok := data.GetOk("nonce_ttl"); ok {
+		config.NonceTTL = time.Duration(raw.(int)) * time.Second
+	}
+
+	// The window is only checked when one of its bounds is written, so that
+	// unrelated writes still succeed. Roles are not re-checked either way.
+	_, leewayOk := data.GetOk("clock_skew_leeway")
+	_, maxTTLOk := data.GetOk("token_max_ttl")
+	if _, ttlOk := data.GetOk("nonce_ttl"); ttlOk || leewayOk || maxTTLOk {
+		if err := validateNonceWindow(config.NonceTTL, config.ClockSkewLeeway, config.TokenMaxTTL); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if raw, ok := data.GetOk("siws_chain_id"); ok {
+		config.SIWSChainID = raw.(string)
+	}
+
+	if raw, ok := data.GetOk("siws_domain"); ok {
+		config.SIWSDomain = raw.(string)
+	}
+
+	if raw, ok := data.GetOk("siws_statement"); ok {
+		config.SIWSStatement = raw.(string)
+	}
+
+	if raw, ok := data.GetOk("siws_uri"); ok {
+		config.SIWSURI = raw.(string)
+	}
+
+	if strings.ContainsAny(config.SIWSDomain+config.SIWSChainID+config.SIWSURI, " \n") || strings.Contains(config.SIWSStatement, "\n") {
+		return logical.ErrorResponse("sign in with solana fields cannot contain line breaks and only the statement may contain spaces"), nil
+	}
+
+	if raw, ok := data.GetOk("session_base_url"); ok {
+		config.SessionBaseURL = strings.TrimSuffix(raw.(string), "/")
+	}
+
+	if config.SessionBaseURL != "" {
+		if u, err := url.Parse(config.SessionBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return logical.ErrorResponse("session_base_url must be an absolute http or https URL"), nil
+		}
+	}
+
+	if raw, ok := data.GetOk("session_icon_url"); ok {
+		config.SessionIconURL = raw.(string)
+	}
+
+	if config.SessionIconURL != "" {
+		if u, err := url.Parse(config.SessionIconURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return logical.ErrorResponse("session_icon_url must be an absolute http or https URL"), nil
+		}
+	}
+
+	if raw, ok := data.GetOk("stateless_nonces"); ok {
+		config.StatelessNonces = raw.(bool)
+	}
+
+	if config.StatelessNonces {
+		if _, err := s.ensureNonceKey(ctx, req.Storage); err != nil {
+			return nil, err
+		}
 	}
 
 	entry, err := logical.StorageEntryJSON(configStorageKey, config)
@@ -98,17 +372,35 @@ func (s *SolanaAuthBackend) getConfig(ctx context.Context, store logical.Storage
 		return nil, err
 	}
 
+	config := &AuthConfigEntry{
+		TokenParams: tokenutil.TokenParams{
+			TokenTTL:    defaultTokenTtl,
+			TokenMaxTTL: defaultTokenMaxTtl,
+		},
+		LockoutCounterReset:  defaultLockoutCounterReset,
+		LockoutDuration:      defaultLockoutDuration,
+		LockoutThreshold:     defaultLockoutThreshold,
+		MaxNoncesPerKey:      defaultMaxNoncesPerKey,
+		MaxPendingPublicKeys: defaultMaxPendingPublicKeys,
+		NonceClientRateBurst: defaultNonceClientRateBurst,
+		NonceClientRateLimit: defaultNonceClientRateLimit,
+		NonceRateLimit:       defaultNonceRateLimit,
+		NonceRateBurst:       defaultNonceRateBurst,
+		NonceTemplate:        defaultNonceTemplate,
+		NonceTTL:             defaultNonceTTL,
+	}
+
 	if entry == nil {
-		return &AuthConfigEntry{
-			TokenTtl:    defaultTokenTtl,
-			TokenMaxTtl: defaultTokenMaxTtl,
-		}, nil
+		return config, nil
 	}
 
-	var config AuthConfigEntry
-	if err := entry.DecodeJSON(&config); err != nil {
+	// Decoding over the defaults keeps them in place for any fields that
+	// were added after the entry was persisted.
+	if err := entry.DecodeJSON(config); err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	upgradeTokenParams(&config.TokenParams)
+
+	return config, nil
 }
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	rpcConfigStorageKey = "config/rpc"
+	defaultCheckTimeout = 15 * time.Second
+	defaultCommitment   = "confirmed"
+	defaultMaxRetries   = 2
+	defaultRetryBackoff = time.Second
+	maxRPCRetries       = 10
+)
+
+func pathConfigRPC(s *SolanaAuthBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/rpc",
+		Fields: map[string]*framework.FieldSchema{
+			"cache_ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Duration for which fetched accounts are cached by address; 0 disables caching",
+			},
+			"check_timeout": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Overall time allowed for the on-chain checks of a login or renewal, across all requests and retries",
+				Default:     int(defaultCheckTimeout.Seconds()),
+			},
+			"commitment": {
+				Type:          framework.TypeString,
+				Description:   "Commitment level of the state queried for on-chain role bindings",
+				Default:       defaultCommitment,
+				AllowedValues: []any{"processed", "confirmed", "finalized"},
+			},
+			"endpoints": {
+				Type:        framework.TypeSlice,
+				Description: "Ordered list of Solana JSON-RPC endpoints to fail over between, each either a URL or an object with url and headers",
+			},
+			"max_retries": {
+				Type:        framework.TypeInt,
+				Description: "Number of times to retry the list of endpoints after all of them have failed",
+				Default:     defaultMaxRetries,
+			},
+			"retry_backoff": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Initial delay before retrying the list of endpoints, doubled after each attempt",
+				Default:     int(defaultRetryBackoff.Seconds()),
+			},
+			"timeout": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Timeout of each request to an endpoint",
+				Default:     int(defaultRPCTimeout.Seconds()),
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: s.pathConfigRPCWrite,
+				Summary:  "Configure the Solana RPC endpoints",
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: s.pathConfigRPCRead,
+				Summary:  "Read the Solana RPC endpoint configuration",
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: s.pathConfigRPCWrite,
+				Summary:  "Configure the Solana RPC endpoints",
+			},
+		},
+		ExistenceCheck: s.pathConfigRPCExistenceCheck,
+	}
+}
+
+func (s *SolanaAuthBackend) pathConfigRPCExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	entry, err := s.getRPCConfig(ctx, req.Storage)
+	if err != nil {
+		return false, err
+	}
+	return entry != nil, nil
+}
+
+func (s *SolanaAuthBackend) pathConfigRPCRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := s.getRPCConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return nil, nil
+	}
+
+	// Header values and URL paths and queries commonly hold API keys, so
+	// only the header names and the scheme and host of each URL are returned.
+	endpoints := make([]map[string]any, len(config.Endpoints))
+	for i, endpoint := range config.Endpoints {
+		headers := make([]string, 0, len(endpoint.Headers))
+		for name := range endpoint.Headers {
+			headers = append(headers, name)
+		}
+		slices.Sort(headers)
+
+		endpoints[i] = map[string]any{
+			"headers": headers,
+			"url":     redactEndpointURL(endpoint.URL),
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]any{
+			"cache_ttl":     int64(config.CacheTTL.Seconds()),
+			"check_timeout": int64(config.CheckTimeout.Seconds()),
+			"commitment":    config.Commitment,
+			"endpoints":     endpoints,
+			"max_retries":   config.MaxRetries,
+			"retry_backoff": int64(config.RetryBackoff.Seconds()),
+			"timeout":       int64(config.Timeout.Seconds()),
+		},
+	}, nil
+}
+
+// redactEndpointURL strips the user info, path, query and fragment from an
+// endpoint URL.
+func redactEndpointURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
+
+func (s *SolanaAuthBackend) pathConfigRPCWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := s.getRPCConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		config = &RPCConfigEntry{
+			CheckTimeout: defaultCheckTimeout,
+			Commitment:   defaultCommitment,
+			MaxRetries:   defaultMaxRetries,
+			RetryBackoff: defaultRetryBackoff,
+			Timeout:      defaultRPCTimeout,
+		}
+	}
+
+	if raw, ok := data.GetOk("cache_ttl"); ok {
+		config.CacheTTL = time.Duration(raw.(int)) * time.Second
+	}
+
+	if raw, ok := data.GetOk("check_timeout"); ok {
+		config.CheckTimeout = time.Duration(raw.(int)) * time.Second
+	}
+
+	if raw, ok := data.GetOk("commitment"); ok {
+		config.Commitment = raw.(string)
+	}
+
+	if raw, ok := data.GetOk("endpoints"); ok {
+		config.Endpoints, err = parseRPCEndpoints(raw.([]any))
+		if err != nil {
+			return logical.ErrorResponse("invalid endpoints: %v", err), nil
+		}
+	}
+
+	if raw, ok := data.GetOk("max_retries"); ok {
+		config.MaxRetries = raw.(int)
+	}
+
+	if raw, ok := data.GetOk("retry_backoff"); ok {
+		config.RetryBackoff = time.Duration(raw.(int)) * time.Second
+	}
+
+	if raw, ok := data.GetOk("timeout"); ok {
+		config.Timeout = time.Duration(raw.(int)) * time.Second
+	}
+
+	if len(config.Endpoints) == 0 {
+		return logical.ErrorResponse("at least one endpoint is required"), nil
+	}
+
+	if config.MaxRetries < 0 || config.MaxRetries > maxRPCRetries {
+		return logical.ErrorResponse("max_retries must be between 0 and %d", maxRPCRetries), nil
+	}
+
+	if config.CacheTTL < 0 || config.RetryBackoff < 0 {
+		return logical.ErrorResponse("cache_ttl and retry_backoff cannot be negative"), nil
+	}
+
+	if config.CheckTimeout <= 0 || config.Timeout <= 0 {
+		return logical.ErrorResponse("check_timeout and timeout must be positive"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(rpcConfigStorageKey, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	// Cached accounts may belong to a different cluster.
+	s.accountCache.purge()
+
+	return nil, nil
+}
+
+// parseRPCEndpoints parses a list of endpoints given either as URLs or as
+// objects with a url and headers.
+func parseRPCEndpoints(raw []any) ([]RPCEndpoint, error) {
+	endpoints := make([]RPCEndpoint, 0, len(raw))
+
+	for i, item := range raw {
+		var endpoint RPCEndpoint
+
+		switch v := item.(type) {
+		case string:
+			endpoint.URL = v
+		case map[string]any:
+			endpoint.URL, _ = v["url"].(string)
+
+			if headers, ok := v["headers"]; ok {
+				m, ok := headers.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("headers of endpoint %d must be an object", i)
+				}
+
+				endpoint.Headers = make(map[string]string, len(m))
+				for name, value := range m {
+					s, ok := value.(string)
+					if !ok {
+						return nil, fmt.Errorf("header %q of endpoint %d must be a string", name, i)
+					}
+					endpoint.Headers[name] = s
+				}
+			}
+		default:
+			return nil, fmt.Errorf("endpoint %d must be a URL or an object", i)
+		}
+
+		if u, err := url.Parse(endpoint.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("url of endpoint %d must be an absolute http or https URL", i)
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, nil
+}
+
+func (s *SolanaAuthBackend) getRPCConfig(ctx context.Context, store logical.Storage) (*RPCConfigEntry, error) {
+	entry, err := store.Get(ctx, rpcConfigStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	config := &RPCConfigEntry{
+		CheckTimeout: defaultCheckTimeout,
+		MaxRetries:   defaultMaxRetries,
+		RetryBackoff: defaultRetryBackoff,
+		Timeout:      defaultRPCTimeout,
+	}
+
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// newRPCClient returns a client for the configured RPC endpoints.
+func (s *SolanaAuthBackend) newRPCClient(config *RPCConfigEntry) rpcClient {
+	var client rpcClient = newJSONRPCClient(config)
+	if config.CacheTTL > 0 {
+		client = &cachingRPCClient{
+			rpcClient: client,
+			cache:     &s.accountCache,
+			ttl:       config.CacheTTL,
+		}
+	}
+
+	return client
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigTokenParams(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+
+	t.Run("Read Default Configuration", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "config",
+			Storage:   storage,
+		})
+
+		assert.NoError(t, err)
+		assert.EqualValues(t, 3600, resp.Data["token_ttl"])
+		assert.EqualValues(t, 86400, resp.Data["token_max_ttl"])
+		assert.EqualValues(t, 0, resp.Data["nonce_rate_limit"])
+		assert.EqualValues(t, defaultNonceClientRateLimit, resp.Data["nonce_client_rate_limit"])
+		assert.EqualValues(t, 0, resp.Data["lockout_threshold"])
+	})
+
+	t.Run("Reject Periodic Batch Tokens", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"token_type":   "batch",
+				"token_period": "1h",
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Login with Configured Token Parameters", func(t *testing.T) {
+		t.Helper()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"token_policies":          "default,reader",
+				"token_period":            "2h",
+				"token_bound_cidrs":       "10.0.0.0/8",
+				"token_no_default_policy": true,
+			},
+		})
+		assert.NoError(t, err)
+
+		resp, err := loginWithWallet(t, backend, storage, wallet, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"default", "reader"}, resp.Auth.Policies)
+		assert.Equal(t, 2*time.Hour, resp.Auth.Period)
+		assert.Equal(t, time.Hour, resp.Auth.TTL)
+		assert.True(t, resp.Auth.NoDefaultPolicy)
+		assert.Len(t, resp.Auth.BoundCIDRs, 1)
+	})
+
+	t.Run("Upgrade Legacy Second Durations", func(t *testing.T) {
+		t.Helper()
+
+		entry, err := logical.StorageEntryJSON(configStorageKey, map[string]any{
+			"token_ttl":     600,
+			"token_max_ttl": 1200,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, storage.Put(context.Background(), entry))
+
+		config, err := backend.getConfig(context.Background(), storage)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 10*time.Minute, config.TokenTTL)
+		assert.Equal(t, 20*time.Minute, config.TokenMaxTTL)
+	})
+
+	t.Run("Check Nonce Window Only When Written", func(t *testing.T) {
+		t.Helper()
+
+		config, err := backend.getConfig(context.Background(), storage)
+		assert.NoError(t, err)
+
+		config.TokenTTL = 30 * time.Second
+		config.TokenMaxTTL = time.Minute
+		config.NonceTTL = 5 * time.Minute
+
+		entry, err := logical.StorageEntryJSON(configStorageKey, config)
+		assert.NoError(t, err)
+		assert.NoError(t, storage.Put(context.Background(), entry))
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"allow_roleless_login": false,
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+
+		resp, err = backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"nonce_ttl": 300,
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+}
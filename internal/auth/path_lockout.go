@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	lockoutStoragePrefix = "lockout/"
+)
+
+func pathLockout(s *SolanaAuthBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "locked-keys/" + framework.GenericNameRegex("public_key"),
+			Fields: map[string]*framework.FieldSchema{
+				"public_key": {
+					Type:        framework.TypeString,
+					Description: "The base-58 public key of the locked wallet",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: s.pathLockoutRead,
+					Summary:  "Read the lockout status of a public key",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: s.pathLockoutDelete,
+					Summary:  "Clear the lockout of a public key",
+				},
+			},
+		},
+		{
+			Pattern: "locked-keys/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: s.pathLockoutList,
+					Summary:  "List all public keys that are currently locked out",
+				},
+			},
+		},
+	}
+}
+
+func (s *SolanaAuthBackend) pathLockoutDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pubkey := data.Get("public_key").(string)
+	if pubkey == "" {
+		return logical.ErrorResponse("missing public key"), nil
+	}
+
+	if err := s.clearFailedLogins(ctx, req.Storage, pubkey); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (s *SolanaAuthBackend) pathLockoutList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	keys, err := req.Storage.List(ctx, lockoutStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	locked := make([]string, 0, len(keys))
+
+	for _, pubkey := range keys {
+		entry, err := s.getLockout(ctx, req.Storage, pubkey)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry != nil && entry.isLocked(now) {
+			locked = append(locked, pubkey)
+		}
+	}
+
+	return logical.ListResponse(locked), nil
+}
+
+func (s *SolanaAuthBackend) pathLockoutRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pubkey := data.Get("public_key").(string)
+	if pubkey == "" {
+		return logical.ErrorResponse("missing public key"), nil
+	}
+
+	entry, err := s.getLockout(ctx, req.Storage, pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]any{
+			"failed_attempts": entry.FailedAttempts,
+			"last_failed_at":  entry.LastFailedAt,
+			"locked":          entry.isLocked(time.Now()),
+			"locked_until":    entry.LockedUntil,
+		},
+	}, nil
+}
+
+// isLockedOut reports whether logins for the public key are currently
+// refused because of repeated failures.
+func (s *SolanaAuthBackend) isLockedOut(ctx context.Context, store logical.Storage, config *AuthConfigEntry, pubkey string) (bool, error) {
+	if config.LockoutThreshold == 0 {
+		return false, nil
+	}
+
+	entry, err := s.getLockout(ctx, store, pubkey)
+	if err != nil || entry == nil {
+		return false, err
+	}
+
+	return entry.isLocked(time.Now()), nil
+}
+
+func (s *SolanaAuthBackend) recordFailedLogin(ctx context.Context, store logical.Storage, config *AuthConfigEntry, pubkey string) error {
+	if config.LockoutThreshold == 0 {
+		return nil
+	}
+
+	s.lockoutLock.Lock()
+	defer s.lockoutLock.Unlock()
+
+	entry, err := s.getLockout(ctx, store, pubkey)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		entry = &LockoutEntry{}
+	}
+
+	now := time.Now()
+
+	if now.Sub(time.Unix(entry.LastFailedAt, 0)) > config.LockoutCounterReset {
+		entry.FailedAttempts = 0
+	}
+
+	entry.FailedAttempts++
+	entry.LastFailedAt = now.Unix()
+
+	if entry.FailedAttempts >= config.LockoutThreshold {
+		entry.FailedAttempts = 0
+		entry.LockedUntil = now.Add(config.LockoutDuration).Unix()
+	}
+
+	return s.setLockout(ctx, store, pubkey, entry)
+}
+
+func (s *SolanaAuthBackend) clearFailedLogins(ctx context.Context, store logical.Storage, pubkey string) error {
+	s.lockoutLock.Lock()
+	defer s.lockoutLock.Unlock()
+
+	entry, err := s.getLockout(ctx, store, pubkey)
+	if err != nil || entry == nil {
+		return err
+	}
+
+	return store.Delete(ctx, lockoutStoragePrefix+pubkey)
+}
+
+func (s *SolanaAuthBackend) getLockout(ctx context.Context, store logical.Storage, pubkey string) (*LockoutEntry, error) {
+	entry, err := store.Get(ctx, lockoutStoragePrefix+pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var lockout LockoutEntry
+	if err := entry.DecodeJSON(&lockout); err != nil {
+		return nil, err
+	}
+
+	return &lockout, nil
+}
+
+func (s *SolanaAuthBackend) setLockout(ctx context.Context, store logical.Storage, pubkey string, lockout *LockoutEntry) error {
+	entry, err := logical.StorageEntryJSON(lockoutStoragePrefix+pubkey, lockout)
+	if err != nil {
+		return err
+	}
+
+	return store.Put(ctx, entry)
+}
+
+func (l *LockoutEntry) isLocked(now time.Time) bool {
+	return now.Unix() < l.LockedUntil
+}
+
+// isStale reports whether the entry no longer affects logins, since its
+// lockout has ended and its failure counter would be reset.
+func (l *LockoutEntry) isStale(now time.Time, counterReset time.Duration) bool {
+	return !l.isLocked(now) && now.Sub(time.Unix(l.LastFailedAt, 0)) > counterReset
+}
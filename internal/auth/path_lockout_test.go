@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+)
+
+func TestFailedLoginLockout(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+	impostor := solana.NewWallet()
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]any{
+			"lockout_threshold": 2,
+			"nonce_rate_limit":  0,
+		},
+	})
+	assert.NoError(t, err)
+
+	attemptLogin := func(signer *solana.Wallet) *logical.Response {
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+			},
+		})
+		assert.NoError(t, err)
+
+		msg := message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			MessageBody: []byte(resp.Data["nonce"].(string)),
+			Version:     0,
+		})
+
+		signature, err := signer.PrivateKey.Sign(msg)
+		assert.NoError(t, err)
+
+		resp, err = backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"nonce_id":   resp.Data["nonce_id"].(string),
+				"signature":  signature.String(),
+			},
+		})
+		assert.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("Lock Out After Failed Attempts", func(t *testing.T) {
+		t.Helper()
+
+		for range 2 {
+			resp := attemptLogin(impostor)
+			assert.EqualError(t, resp.Error(), loginFailedMessage)
+		}
+
+		resp := attemptLogin(wallet)
+		assert.EqualError(t, resp.Error(), loginFailedMessage)
+	})
+
+	t.Run("List Locked Keys", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "locked-keys",
+			Storage:   storage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{wallet.PublicKey().String()}, resp.Data["keys"])
+	})
+
+	t.Run("Clear Lockout", func(t *testing.T) {
+		t.Helper()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "locked-keys/" + wallet.PublicKey().String(),
+			Storage:   storage,
+		})
+		assert.NoError(t, err)
+
+		resp := attemptLogin(wallet)
+		assert.NotNil(t, resp.Auth)
+	})
+}
@@ -3,14 +3,24 @@ package auth
 import (
 	"context"
 	"crypto/ed25519"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/policyutil"
+	"github.com/hashicorp/vault/sdk/helper/tokenutil"
 	"github.com/hashicorp/vault/sdk/logical"
 
 	"github.com/callensm/vault-plugin-solana/internal/message"
+	"github.com/callensm/vault-plugin-solana/internal/offchain"
+)
+
+const (
+	loginFailedMessage = "signature verification failed"
 )
 
 func pathLogin(s *SolanaAuthBackend) *framework.Path {
@@ -22,11 +32,43 @@ func pathLogin(s *SolanaAuthBackend) *framework.Path {
 				Description: "The base-58 public key of the wallet to authenticate",
 				Required:    true,
 			},
-			"signature": {
+			"nonce_id": {
 				Type:        framework.TypeString,
-				Description: "The base-58 nonce message signature to be verified",
+				Description: "The identifier of the nonce that was signed",
 				Required:    true,
 			},
+			"delegation": {
+				Type:        framework.TypeString,
+				Description: "A delegation certificate signed by public_key authorizing a delegate key to sign the nonce on its behalf",
+			},
+			"delegation_signature": {
+				Type:        framework.TypeString,
+				Description: "The signature of the delegation certificate by public_key, in any of the encodings accepted for signature",
+			},
+			"cosigner_public_key": {
+				Type:        framework.TypeString,
+				Description: "The base-58 public key of the approver co-signing the login, for roles that require a co-signer",
+			},
+			"cosigner_signature": {
+				Type:        framework.TypeString,
+				Description: "The co-signer's signature of the same nonce message, in any of the encodings accepted for signature",
+			},
+			"role": {
+				Type:        framework.TypeString,
+				Description: "Name of the role to authenticate against",
+			},
+			"signed_message": {
+				Type:        framework.TypeString,
+				Description: "The Sign-In With Solana message text that was signed, when using the siws login mode",
+			},
+			"signature": {
+				Type:        framework.TypeString,
+				Description: "The nonce message signature to be verified, encoded as base-58, base-64, hex or the Solana CLI JSON output",
+			},
+			"transaction": {
+				Type:        framework.TypeString,
+				Description: "The base-64 serialized and signed memo transaction, when using the transaction login mode",
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
@@ -43,28 +85,43 @@ func (s *SolanaAuthBackend) pathLoginUpdate(ctx context.Context, req *logical.Re
 		return logical.ErrorResponse("missing or empty public key"), nil
 	}
 
-	signature, ok := data.Get("signature").(string)
-	if !ok || signature == "" {
-		return logical.ErrorResponse("missing or empty signature"), nil
+	nonceID, ok := data.Get("nonce_id").(string)
+	if !ok || nonceID == "" {
+		return logical.ErrorResponse("missing or empty nonce id"), nil
 	}
 
-	storageKey := fmt.Sprintf(nonceStorageFormat, pubkey)
-	entry, err := req.Storage.Get(ctx, storageKey)
+	roleName := data.Get("role").(string)
+
+	config, err := s.getConfig(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
 
-	if entry == nil {
-		return logical.ErrorResponse("nonce not found"), nil
+	var role *RoleEntry
+	if roleName != "" {
+		role, err = s.getRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+
+		if role != nil && role.isMultisig() {
+			return s.multisigLogin(ctx, req, data, config, roleName, role)
+		}
 	}
 
-	var storedNonce NonceEntry
-	if err := entry.DecodeJSON(&storedNonce); err != nil {
+	storedNonce, err := s.lookupNonce(ctx, req.Storage, config, pubkey, nonceID)
+	if err != nil {
 		return nil, err
 	}
 
-	if time.Now().Unix() > storedNonce.ExpiresAt {
-		req.Storage.Delete(ctx, storageKey)
+	if storedNonce == nil {
+		return logical.ErrorResponse("nonce not found"), nil
+	}
+
+	if storedNonce.isExpired(time.Now().Unix()) {
+		if err := s.deleteNonce(ctx, req.Storage, storedNonce); err != nil {
+			return nil, err
+		}
 		return logical.ErrorResponse("nonce expired"), nil
 	}
 
@@ -72,47 +129,337 @@ func (s *SolanaAuthBackend) pathLoginUpdate(ctx context.Context, req *logical.Re
 		return logical.ErrorResponse("public key mismatch"), nil
 	}
 
-	sig, err := solana.SignatureFromBase58(signature)
-	if err != nil {
-		return logical.ErrorResponse("invalid signature"), nil
+	if storedNonce.Role != roleName {
+		return logical.ErrorResponse("role mismatch"), nil
 	}
 
-	pk, err := solana.PublicKeyFromBase58(pubkey)
+	pk, err := parsePublicKey(pubkey)
 	if err != nil {
 		return logical.ErrorResponse("invalid public key"), nil
 	}
 
-	msg := message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
-		MessageBody: []byte(storedNonce.Nonce),
-		Version:     0,
-	})
+	// A locked out public key receives the same response as an invalid
+	// signature so that its lockout status is not disclosed.
+	locked, err := s.isLockedOut(ctx, req.Storage, config, pubkey)
+	if err != nil {
+		return nil, err
+	}
 
-	if !ed25519.Verify(ed25519.PublicKey(pk[:]), msg, sig[:]) {
-		return logical.ErrorResponse("signature verification failed"), nil
+	if locked {
+		return logical.ErrorResponse(loginFailedMessage), nil
 	}
 
-	req.Storage.Delete(ctx, storageKey)
+	// A delegated login is signed by the delegate key authorized in the
+	// certificate, but is otherwise performed as the identity key.
+	signer := pk
+	now := time.Now()
 
-	config, err := s.getConfig(ctx, req.Storage)
-	if err != nil {
+	var delegation *message.Delegation
+	if certificate := data.Get("delegation").(string); certificate != "" {
+		if storedNonce.Mode == loginModeSIWS {
+			return logical.ErrorResponse("delegated login is not supported by the siws login mode"), nil
+		}
+
+		delegation, signer, err = verifyDelegation(pk, req.MountPoint, certificate, data.Get("delegation_signature").(string), now)
+		if errors.Is(err, errDelegationSignature) {
+			if err := s.recordFailedLogin(ctx, req.Storage, config, pubkey); err != nil {
+				return nil, err
+			}
+			return logical.ErrorResponse(loginFailedMessage), nil
+		} else if err != nil {
+			return logical.ErrorResponse("invalid delegation: %v", err), nil
+		}
+	}
+
+	var msg []byte
+	var sig solana.Signature
+
+	var challenge string
+	if storedNonce.Mode != loginModeSIWS {
+		challenge, err = s.challengeMessage(ctx, req, config, storedNonce)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if storedNonce.Mode == loginModeTransaction {
+		msg, sig, err = verifyLoginTransaction(challenge, signer, data.Get("transaction").(string))
+		if err != nil {
+			return logical.ErrorResponse("invalid transaction: %v", err), nil
+		}
+	} else {
+		signature, ok := data.Get("signature").(string)
+		if !ok || signature == "" {
+			return logical.ErrorResponse("missing or empty signature"), nil
+		}
+
+		switch storedNonce.Mode {
+		case loginModeSIWS:
+			msg, err = verifySignInMessage(config, storedNonce, data.Get("signed_message").(string))
+			if err != nil {
+				return logical.ErrorResponse("invalid sign in message: %v", err), nil
+			}
+		default:
+			msg, err = offchain.Message(challenge)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		sig, err = message.ParseSignature(signature)
+		if err != nil {
+			return logical.ErrorResponse("invalid signature: %v", err), nil
+		}
+	}
+
+	if resp, err := s.verifySignature(ctx, req.Storage, config, pubkey, signer, msg, sig); resp != nil || err != nil {
+		return resp, err
+	}
+
+	// High-privilege roles additionally require an approver to co-sign the
+	// challenge, or the message text for sign in with solana. The nonce is
+	// only redeemed once both signatures are valid.
+	var cosigner string
+	if role != nil && role.requiresCosigner() {
+		if storedNonce.Mode == loginModeSIWS {
+			challenge = string(msg)
+		}
+
+		cosigner = data.Get("cosigner_public_key").(string)
+		err := verifyCosignature(role, challenge, cosigner, data.Get("cosigner_signature").(string), pk, signer)
+		if errors.Is(err, errCosignature) {
+			return logical.ErrorResponse(err.Error()), nil
+		} else if err != nil {
+			return logical.ErrorResponse("invalid co-signature: %v", err), nil
+		}
+	}
+
+	redeemed, err := s.redeemNonce(ctx, req.Storage, storedNonce)
+	if errors.Is(err, errUsedNonceCacheFull) {
+		return logical.ErrorResponse(err.Error()), logical.ErrRateLimitQuotaExceeded
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !redeemed {
+		return logical.ErrorResponse("nonce not found"), nil
+	}
+
+	params, role, err := s.loginTokenParams(ctx, req.Storage, config, roleName, pubkey)
+	if err == nil {
+		err = s.checkOnChainBindings(ctx, req.Storage, role, pk)
+	}
+
+	if denied := (*deniedError)(nil); errors.As(err, &denied) {
+		return logical.ErrorResponse(denied.Error()), nil
+	} else if err != nil {
 		return nil, err
 	}
 
+	auth := walletAuth(pubkey, roleName)
+	params.PopulateTokenAuth(auth)
+
+	if cosigner != "" {
+		auth.InternalData["cosigner"] = cosigner
+		auth.Metadata["cosigner"] = cosigner
+	}
+
+	if delegation != nil {
+		auth.InternalData["delegate"] = delegation.Delegate
+		auth.InternalData["delegation_expires_at"] = delegation.ExpiresAt.Format(time.RFC3339)
+		auth.Metadata["delegate"] = delegation.Delegate
+
+		auth.TTL = capDelegatedTTL(auth.TTL, delegation.ExpiresAt, now)
+		auth.ExplicitMaxTTL = capDelegatedTTL(auth.ExplicitMaxTTL, delegation.ExpiresAt, now)
+	}
+
 	return &logical.Response{
-		Auth: &logical.Auth{
-			InternalData: map[string]any{
-				"public_key": pubkey,
-			},
-			Policies: config.TokenPolicies,
+		Auth: auth,
+	}, nil
+}
+
+func (s *SolanaAuthBackend) pathLoginRenew(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName, _ := req.Auth.InternalData["role"].(string)
+
+	var params *tokenutil.TokenParams
+	var err error
+
+	if signers, ok := req.Auth.InternalData["signers"].(string); ok {
+		params, err = s.multisigTokenParams(ctx, req.Storage, roleName, strings.Split(signers, ","))
+	} else {
+		pubkey, _ := req.Auth.InternalData["public_key"].(string)
+		if pubkey == "" {
+			return nil, errors.New("no public key associated with token")
+		}
+
+		cosigner, _ := req.Auth.InternalData["cosigner"].(string)
+
+		params, err = s.renewTokenParams(ctx, req.Storage, roleName, pubkey, cosigner)
+	}
+
+	if denied := (*deniedError)(nil); errors.As(err, &denied) {
+		return logical.ErrorResponse("renewal denied: %v", denied), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !policyutil.EquivalentPolicies(params.TokenPolicies, req.Auth.TokenPolicies) {
+		return logical.ErrorResponse("renewal denied: policies have changed"), nil
+	}
+
+	resp := &logical.Response{Auth: req.Auth}
+	resp.Auth.TTL = params.TokenTTL
+	resp.Auth.MaxTTL = params.TokenMaxTTL
+	resp.Auth.Period = params.TokenPeriod
+
+	if raw, ok := req.Auth.InternalData["delegation_expires_at"].(string); ok {
+		expiresAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delegation expiry associated with token: %w", err)
+		}
+
+		now := time.Now()
+		if !now.Before(expiresAt) {
+			return logical.ErrorResponse("renewal denied: delegation expired"), nil
+		}
+
+		resp.Auth.TTL = capDelegatedTTL(resp.Auth.TTL, expiresAt, now)
+	}
+
+	return resp, nil
+}
+
+// verifySignature verifies the signature of the message by the signer on
+// behalf of the public key, recording a failed login for the public key if it
+// is invalid and clearing its failed logins otherwise. An error response is
+// returned for invalid signatures.
+func (s *SolanaAuthBackend) verifySignature(ctx context.Context, store logical.Storage, config *AuthConfigEntry, pubkey string, signer solana.PublicKey, msg []byte, sig solana.Signature) (*logical.Response, error) {
+	if !ed25519.Verify(ed25519.PublicKey(signer[:]), msg, sig[:]) {
+		if err := s.recordFailedLogin(ctx, store, config, pubkey); err != nil {
+			return nil, err
+		}
+		return logical.ErrorResponse(loginFailedMessage), nil
+	}
+
+	return nil, s.clearFailedLogins(ctx, store, pubkey)
+}
+
+// verifyChallengeSignature verifies the public key's signature of the
+// challenge of the nonce, as an offchain message or otherwise as the raw
+// text, refusing public keys that are locked out. An error response is
+// returned for invalid signatures.
+func (s *SolanaAuthBackend) verifyChallengeSignature(ctx context.Context, req *logical.Request, config *AuthConfigEntry, nonce *NonceEntry, pubkey string, pk solana.PublicKey, sig solana.Signature, asOffchain bool) (*logical.Response, error) {
+	locked, err := s.isLockedOut(ctx, req.Storage, config, pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	if locked {
+		return logical.ErrorResponse(loginFailedMessage), nil
+	}
+
+	challenge, err := s.challengeMessage(ctx, req, config, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := []byte(challenge)
+	if asOffchain {
+		msg, err = offchain.Message(challenge)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.verifySignature(ctx, req.Storage, config, pubkey, pk, msg, sig)
+}
+
+// walletAuth returns the auth for a token issued to the public key.
+func walletAuth(pubkey, roleName string) *logical.Auth {
+	return &logical.Auth{
+		InternalData: map[string]any{
+			"public_key": pubkey,
+			"role":       roleName,
+		},
+		Metadata: map[string]string{
+			"public_key": pubkey,
+			"role":       roleName,
+		},
+		DisplayName: fmt.Sprintf("solana-%s", pubkey[:8]),
+		Alias: &logical.Alias{
+			Name: pubkey,
 			Metadata: map[string]string{
 				"public_key": pubkey,
-			},
-			DisplayName: fmt.Sprintf("solana-%s", pubkey[:8]),
-			LeaseOptions: logical.LeaseOptions{
-				TTL:       time.Duration(config.TokenTtl) * time.Second,
-				MaxTTL:    time.Duration(config.TokenMaxTtl) * time.Second,
-				Renewable: true,
+				"role":       roleName,
 			},
 		},
-	}, nil
+	}
+}
+
+// renewTokenParams returns the token parameters for renewing a token issued
+// to the public key, re-evaluating the on-chain bindings of its role so that
+// access ends once the wallet no longer satisfies them rather than at the
+// token's max TTL.
+func (s *SolanaAuthBackend) renewTokenParams(ctx context.Context, store logical.Storage, roleName, pubkey, cosigner string) (*tokenutil.TokenParams, error) {
+	config, err := s.getConfig(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := solana.PublicKeyFromBase58(pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key associated with token: %w", err)
+	}
+
+	params, role, err := s.loginTokenParams(ctx, store, config, roleName, pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != nil && role.requiresCosigner() && !slices.Contains(role.CosignerPublicKeys, cosigner) {
+		return nil, &deniedError{"co-signer is no longer permitted by the role"}
+	}
+
+	if err := s.checkOnChainBindings(ctx, store, role, pk); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// errRoleRequired is returned to wallets logging in without a role unless
+// role-less login has been allowed in the backend configuration.
+const errRoleRequired = "missing role; login without a role is not allowed"
+
+// deniedError carries the reason a public key is not permitted to login.
+type deniedError struct {
+	reason string
+}
+
+func (e *deniedError) Error() string {
+	return e.reason
+}
+
+// loginTokenParams returns the token parameters and role for a public key
+// logging in with the role, or the backend configuration when no role is
+// given and role-less login is allowed. A deniedError is returned if the
+// public key is not permitted to use the role.
+func (s *SolanaAuthBackend) loginTokenParams(ctx context.Context, store logical.Storage, config *AuthConfigEntry, roleName, pubkey string) (*tokenutil.TokenParams, *RoleEntry, error) {
+	if roleName == "" {
+		if !config.AllowRolelessLogin {
+			return nil, nil, &deniedError{errRoleRequired}
+		}
+		return &config.TokenParams, nil, nil
+	}
+
+	role, err := s.getRole(ctx, store, roleName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if role == nil || !role.permitsPublicKey(pubkey) {
+		return nil, nil, &deniedError{"invalid role or public key"}
+	}
+
+	return &role.TokenParams, role, nil
 }
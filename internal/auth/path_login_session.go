@@ -0,0 +1,587 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+)
+
+const (
+	loginSessionStoragePrefix = "login_session/"
+
+	errSessionRoleUnsupported = "login sessions are not supported by multi-signature or co-signed roles"
+)
+
+func pathLoginSession(s *SolanaAuthBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "login/session$",
+			Fields: map[string]*framework.FieldSchema{
+				"public_key": {
+					Type:        framework.TypeString,
+					Description: "The base-58 public key of the wallet expected to sign the session",
+					Required:    true,
+				},
+				"role": {
+					Type:        framework.TypeString,
+					Description: "Name of the role to authenticate against",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: s.pathLoginSessionCreate,
+					Summary:  "Create a cross-device login session",
+				},
+			},
+		},
+		{
+			Pattern: "login/session/" + framework.GenericNameRegex("session_id") + "/signature$",
+			Fields: map[string]*framework.FieldSchema{
+				"session_id": {
+					Type:        framework.TypeString,
+					Description: "The identifier of the login session",
+				},
+				"public_key": {
+					Type:        framework.TypeString,
+					Description: "The base-58 public key of the wallet that signed the session challenge",
+					Required:    true,
+				},
+				"signature": {
+					Type:        framework.TypeString,
+					Description: "The session challenge signature, encoded as base-58, base-64, hex or the Solana CLI JSON output",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: s.pathLoginSessionSign,
+					Summary:  "Submit the signature of a login session challenge",
+				},
+			},
+		},
+		{
+			Pattern: "login/session/" + framework.GenericNameRegex("session_id") + "/request$",
+			Fields: map[string]*framework.FieldSchema{
+				"session_id": {
+					Type:        framework.TypeString,
+					Description: "The identifier of the login session",
+				},
+				"account": {
+					Type:        framework.TypeString,
+					Description: "The base-58 public key of the wallet, as sent by Solana Pay wallets",
+				},
+				"state": {
+					Type:        framework.TypeString,
+					Description: "The state returned with the session challenge, as sent by Solana Pay wallets",
+				},
+				"signature": {
+					Type:        framework.TypeString,
+					Description: "The base-58 signature of the session challenge, as sent by Solana Pay wallets",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: s.pathLoginSessionRequestRead,
+					Summary:  "Describe a login session to a Solana Pay wallet",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: s.pathLoginSessionRequestUpdate,
+					Summary:  "Return the challenge of a login session to a Solana Pay wallet, or accept its signature",
+				},
+			},
+		},
+		{
+			Pattern: "login/session/" + framework.GenericNameRegex("session_id") + "$",
+			Fields: map[string]*framework.FieldSchema{
+				"session_id": {
+					Type:        framework.TypeString,
+					Description: "The identifier of the login session",
+				},
+				"poll_token": {
+					Type:        framework.TypeString,
+					Description: "The secret returned when the session was created",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: s.pathLoginSessionPoll,
+					Summary:  "Poll a login session, returning a token once it has been signed",
+				},
+			},
+		},
+	}
+}
+
+func (s *SolanaAuthBackend) pathLoginSessionCreate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := s.getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.SessionBaseURL == "" {
+		return logical.ErrorResponse("login sessions are not configured"), nil
+	}
+
+	// Sessions are bound to the wallet expected to sign them, since anyone
+	// who learns the session identifier could otherwise sign it themselves.
+	pubkey, ok := data.Get("public_key").(string)
+	if !ok || pubkey == "" {
+		return logical.ErrorResponse("missing or empty public key"), nil
+	}
+
+	if _, err := parsePublicKey(pubkey); err != nil {
+		return logical.ErrorResponse("invalid public key: %v", err), nil
+	}
+
+	if resp, err := s.limitNonceRequests(req, config, pubkey); resp != nil || err != nil {
+		return resp, err
+	}
+
+	var role *RoleEntry
+
+	roleName := data.Get("role").(string)
+	if roleName == "" && !config.AllowRolelessLogin {
+		return logical.ErrorResponse(errRoleRequired), nil
+	}
+
+	if roleName != "" {
+		role, err = s.getRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+
+		if role == nil || !role.permitsPublicKey(pubkey) {
+			return logical.ErrorResponse("invalid role or public key"), nil
+		}
+
+		if !role.supportsLoginSessions() {
+			return logical.ErrorResponse(errSessionRoleUnsupported), nil
+		}
+	}
+
+	ok, err = s.pendingSessions.reserve(ctx, req.Storage, loginSessionStoragePrefix, config.MaxPendingPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return logical.ErrorResponse("too many pending login sessions, try again later"), logical.ErrRateLimitQuotaExceeded
+	}
+
+	nonce, ttl, err := newNonceEntry(config, role, roleName, pubkey, loginModeOffchain)
+	if err != nil {
+		return nil, err
+	}
+
+	pollTokenBytes := make([]byte, 32)
+	if _, err := rand.Read(pollTokenBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate poll token: %v", err)
+	}
+	pollToken := base64.RawURLEncoding.EncodeToString(pollTokenBytes)
+
+	session := &LoginSessionEntry{
+		NonceEntry:    *nonce,
+		PollTokenHash: hashPollToken(pollToken),
+	}
+
+	challenge, err := s.issueChallenge(ctx, req, config, &session.NonceEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := putLoginSession(ctx, req.Storage, session); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]any{
+			"expires_at":    session.ExpiresAt,
+			"nonce":         challenge,
+			"poll_token":    pollToken,
+			"deep_link":     sessionDeepLink(config, req.MountPoint, session.ID),
+			"session_id":    session.ID,
+			"signature_url": sessionSignatureURL(config, req.MountPoint, session.ID),
+			"ttl":           int64(ttl.Seconds()),
+		},
+	}, nil
+}
+
+func (s *SolanaAuthBackend) pathLoginSessionSign(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id := data.Get("session_id").(string)
+
+	pubkey, ok := data.Get("public_key").(string)
+	if !ok || pubkey == "" {
+		return logical.ErrorResponse("missing or empty public key"), nil
+	}
+
+	signature, ok := data.Get("signature").(string)
+	if !ok || signature == "" {
+		return logical.ErrorResponse("missing or empty signature"), nil
+	}
+
+	pk, err := parsePublicKey(pubkey)
+	if err != nil {
+		return logical.ErrorResponse("invalid public key"), nil
+	}
+
+	sig, err := message.ParseSignature(signature)
+	if err != nil {
+		return logical.ErrorResponse("invalid signature: %v", err), nil
+	}
+
+	config, err := s.getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.signLoginSession(ctx, req, config, id, pubkey, pk, sig, true)
+}
+
+func (s *SolanaAuthBackend) pathLoginSessionRequestRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := s.getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.SessionBaseURL == "" {
+		return logical.ErrorResponse("login sessions are not configured"), nil
+	}
+
+	body := map[string]any{
+		"label": sessionLabel(config),
+	}
+
+	if config.SessionIconURL != "" {
+		body["icon"] = config.SessionIconURL
+	}
+
+	return solanaPayResponse(body)
+}
+
+// pathLoginSessionRequestUpdate implements both requests a Solana Pay wallet
+// makes to sign a message: the first, with only the account, returns the
+// challenge as the data to sign, and the second carries its signature.
+func (s *SolanaAuthBackend) pathLoginSessionRequestUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id := data.Get("session_id").(string)
+
+	account, ok := data.Get("account").(string)
+	if !ok || account == "" {
+		return logical.ErrorResponse("missing or empty account"), nil
+	}
+
+	config, err := s.getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := data.Get("signature").(string)
+	if signature == "" {
+		challenge, resp, err := s.loginSessionChallenge(ctx, req, config, id, account)
+		if resp != nil || err != nil {
+			return resp, err
+		}
+
+		return solanaPayResponse(map[string]any{
+			"data":    challenge,
+			"message": sessionLabel(config),
+			"state":   id,
+		})
+	}
+
+	if data.Get("state").(string) != id {
+		return logical.ErrorResponse("state mismatch"), nil
+	}
+
+	pk, err := parsePublicKey(account)
+	if err != nil {
+		return logical.ErrorResponse("invalid public key"), nil
+	}
+
+	sig, err := message.ParseSignature(signature)
+	if err != nil {
+		return logical.ErrorResponse("invalid signature: %v", err), nil
+	}
+
+	// Wallets sign the data as given rather than as an offchain message.
+	if resp, err := s.signLoginSession(ctx, req, config, id, account, pk, sig, false); resp != nil || err != nil {
+		return resp, err
+	}
+
+	return solanaPayResponse(map[string]any{
+		"message": "Signed in, return to the device that started the login",
+	})
+}
+
+// loginSessionChallenge returns the challenge of a session that is still
+// waiting to be signed by the public key.
+func (s *SolanaAuthBackend) loginSessionChallenge(ctx context.Context, req *logical.Request, config *AuthConfigEntry, id, pubkey string) (string, *logical.Response, error) {
+	s.sessionLock.Lock()
+	defer s.sessionLock.Unlock()
+
+	session, resp, err := s.signableLoginSession(ctx, req.Storage, id, pubkey)
+	if resp != nil || err != nil {
+		return "", resp, err
+	}
+
+	challenge, err := s.challengeMessage(ctx, req, config, &session.NonceEntry)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return challenge, nil, nil
+}
+
+// signLoginSession verifies the public key's signature of the session
+// challenge and marks the session as signed, so that it can be claimed by
+// polling.
+func (s *SolanaAuthBackend) signLoginSession(ctx context.Context, req *logical.Request, config *AuthConfigEntry, id, pubkey string, pk solana.PublicKey, sig solana.Signature, asOffchain bool) (*logical.Response, error) {
+	s.sessionLock.Lock()
+	defer s.sessionLock.Unlock()
+
+	session, resp, err := s.signableLoginSession(ctx, req.Storage, id, pubkey)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	if resp, err := s.verifyChallengeSignature(ctx, req, config, &session.NonceEntry, pubkey, pk, sig, asOffchain); resp != nil || err != nil {
+		return resp, err
+	}
+
+	session.Signer = pubkey
+	if err := putLoginSession(ctx, req.Storage, session); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// signableLoginSession returns the session if it is still waiting to be
+// signed by the public key, or otherwise a response reporting why it cannot
+// be signed. The caller must hold the session lock.
+func (s *SolanaAuthBackend) signableLoginSession(ctx context.Context, store logical.Storage, id, pubkey string) (*LoginSessionEntry, *logical.Response, error) {
+	session, err := getLoginSession(ctx, store, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if session == nil {
+		return nil, logical.ErrorResponse("session not found"), nil
+	}
+
+	if session.isExpired(time.Now().Unix()) {
+		if err := store.Delete(ctx, loginSessionStoragePrefix+id); err != nil {
+			return nil, nil, err
+		}
+		return nil, logical.ErrorResponse("session expired"), nil
+	}
+
+	if session.Signer != "" {
+		return nil, logical.ErrorResponse("session has already been signed"), nil
+	}
+
+	if session.PublicKey != pubkey {
+		return nil, logical.ErrorResponse("public key mismatch"), nil
+	}
+
+	if session.Role != "" {
+		role, err := s.getRole(ctx, store, session.Role)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if role == nil || !role.permitsPublicKey(pubkey) {
+			return nil, logical.ErrorResponse("invalid role or public key"), nil
+		}
+
+		// The role may have changed since the session was created.
+		if !role.supportsLoginSessions() {
+			return nil, logical.ErrorResponse(errSessionRoleUnsupported), nil
+		}
+	}
+
+	return session, nil, nil
+}
+
+func (s *SolanaAuthBackend) pathLoginSessionPoll(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id := data.Get("session_id").(string)
+
+	pollToken, ok := data.Get("poll_token").(string)
+	if !ok || pollToken == "" {
+		return logical.ErrorResponse("missing or empty poll token"), nil
+	}
+
+	session, resp, err := s.claimLoginSession(ctx, req.Storage, id, pollToken)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	config, err := s.getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := parsePublicKey(session.Signer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key associated with session: %w", err)
+	}
+
+	params, role, err := s.loginTokenParams(ctx, req.Storage, config, session.Role, session.Signer)
+	if err == nil && role != nil && !role.supportsLoginSessions() {
+		err = &deniedError{errSessionRoleUnsupported}
+	}
+
+	if err == nil {
+		err = s.checkOnChainBindings(ctx, req.Storage, role, pk)
+	}
+
+	if denied := (*deniedError)(nil); errors.As(err, &denied) {
+		return logical.ErrorResponse(denied.Error()), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	auth := walletAuth(session.Signer, session.Role)
+	params.PopulateTokenAuth(auth)
+
+	return &logical.Response{
+		Auth: auth,
+	}, nil
+}
+
+// supportsLoginSessions reports whether the role can be logged in with
+// through a session, which carries the signature of a single wallet only.
+func (r *RoleEntry) supportsLoginSessions() bool {
+	return !r.isMultisig() && !r.requiresCosigner()
+}
+
+// claimLoginSession returns the session once it has been signed, deleting it
+// so that only a single token is issued for it. Otherwise a response
+// reporting that it is still pending, or why it cannot be claimed, is
+// returned.
+func (s *SolanaAuthBackend) claimLoginSession(ctx context.Context, store logical.Storage, id, pollToken string) (*LoginSessionEntry, *logical.Response, error) {
+	s.sessionLock.Lock()
+	defer s.sessionLock.Unlock()
+
+	session, err := getLoginSession(ctx, store, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if session == nil || subtle.ConstantTimeCompare([]byte(hashPollToken(pollToken)), []byte(session.PollTokenHash)) != 1 {
+		return nil, logical.ErrorResponse("session not found"), nil
+	}
+
+	if session.isExpired(time.Now().Unix()) {
+		if err := store.Delete(ctx, loginSessionStoragePrefix+id); err != nil {
+			return nil, nil, err
+		}
+		return nil, logical.ErrorResponse("session expired"), nil
+	}
+
+	if session.Signer == "" {
+		return nil, &logical.Response{
+			Data: map[string]any{
+				"expires_at": session.ExpiresAt,
+				"status":     "pending",
+			},
+		}, nil
+	}
+
+	if err := store.Delete(ctx, loginSessionStoragePrefix+id); err != nil {
+		return nil, nil, err
+	}
+
+	return session, nil, nil
+}
+
+// sessionSignatureURL returns the externally reachable URL of the endpoint
+// that accepts the signature of the session challenge.
+func sessionSignatureURL(config *AuthConfigEntry, mount, id string) string {
+	return fmt.Sprintf("%s/v1/%slogin/session/%s/signature", config.SessionBaseURL, mount, id)
+}
+
+// sessionRequestURL returns the externally reachable URL of the endpoint that
+// serves the session to wallets as a Solana Pay sign-message request.
+func sessionRequestURL(config *AuthConfigEntry, mount, id string) string {
+	return fmt.Sprintf("%s/v1/%slogin/session/%s/request", config.SessionBaseURL, mount, id)
+}
+
+// sessionDeepLink returns the solana: URL of the session's sign-message
+// request, to be rendered as a QR code for mobile wallets to scan.
+func sessionDeepLink(config *AuthConfigEntry, mount, id string) string {
+	return "solana:" + url.QueryEscape(sessionRequestURL(config, mount, id))
+}
+
+// sessionLabel returns the name under which wallets present login sessions.
+func sessionLabel(config *AuthConfigEntry) string {
+	if config.Audience != "" {
+		return config.Audience
+	}
+
+	return "Vault"
+}
+
+// solanaPayResponse returns the body as is, rather than wrapped in a Vault
+// response, since Solana Pay wallets expect a bare JSON object.
+func solanaPayResponse(body map[string]any) (*logical.Response, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]any{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     raw,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}, nil
+}
+
+func hashPollToken(pollToken string) string {
+	sum := sha256.Sum256([]byte(pollToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func getLoginSession(ctx context.Context, store logical.Storage, id string) (*LoginSessionEntry, error) {
+	entry, err := store.Get(ctx, loginSessionStoragePrefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var session LoginSessionEntry
+	if err := entry.DecodeJSON(&session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func putLoginSession(ctx context.Context, store logical.Storage, session *LoginSessionEntry) error {
+	entry, err := logical.StorageEntryJSON(loginSessionStoragePrefix+session.ID, session)
+	if err != nil {
+		return err
+	}
+
+	return store.Put(ctx, entry)
+}
@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+)
+
+func TestLoginSession(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+	other := solana.NewWallet()
+
+	createSession := func(data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "login/session",
+			Storage:    storage,
+			MountPoint: "auth/solana/",
+			Data:       data,
+		})
+	}
+
+	signSession := func(wallet *solana.Wallet, id, challenge string) (*logical.Response, error) {
+		sig, err := wallet.PrivateKey.Sign(message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			MessageBody: []byte(challenge),
+			Version:     0,
+		}))
+		assert.NoError(t, err)
+
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "login/session/" + id + "/signature",
+			Storage:    storage,
+			MountPoint: "auth/solana/",
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"signature":  sig.String(),
+			},
+		})
+	}
+
+	poll := func(id, pollToken string) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login/session/" + id,
+			Storage:   storage,
+			Data: map[string]any{
+				"poll_token": pollToken,
+			},
+		})
+	}
+
+	t.Run("Require Session Base URL", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := createSession(nil)
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "not configured")
+	})
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]any{
+			"session_base_url": "https://vault.example.com/",
+		},
+	})
+	assert.NoError(t, err)
+
+	t.Run("Sign and Poll Session", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := createSession(map[string]any{"public_key": wallet.PublicKey().String()})
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+
+		id := resp.Data["session_id"].(string)
+		pollToken := resp.Data["poll_token"].(string)
+		challenge := resp.Data["nonce"].(string)
+
+		assert.Equal(t, "https://vault.example.com/v1/auth/solana/login/session/"+id+"/signature", resp.Data["signature_url"])
+
+		resp, err = poll(id, pollToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "pending", resp.Data["status"])
+		assert.Nil(t, resp.Auth)
+
+		resp, err = signSession(wallet, id, challenge)
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		resp, err = signSession(other, id, challenge)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "already been signed")
+
+		resp, err = poll(id, "wrong")
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+
+		resp, err = poll(id, pollToken)
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+		assert.Equal(t, wallet.PublicKey().String(), resp.Auth.Alias.Name)
+
+		resp, err = poll(id, pollToken)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "session not found")
+	})
+
+	t.Run("Sign through Deep Link", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := createSession(map[string]any{"public_key": wallet.PublicKey().String()})
+		assert.NoError(t, err)
+
+		id := resp.Data["session_id"].(string)
+		pollToken := resp.Data["poll_token"].(string)
+
+		// Follow the link the way a Solana Pay wallet does, requesting the
+		// label, then the message to sign, then submitting its signature.
+		link, err := url.QueryUnescape(strings.TrimPrefix(resp.Data["deep_link"].(string), "solana:"))
+		assert.NoError(t, err)
+		assert.Equal(t, "https://vault.example.com/v1/auth/solana/login/session/"+id+"/request", link)
+
+		request := func(op logical.Operation, data map[string]any) map[string]any {
+			resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+				Operation:  op,
+				Path:       strings.TrimPrefix(link, "https://vault.example.com/v1/auth/solana/"),
+				Storage:    storage,
+				MountPoint: "auth/solana/",
+				Data:       data,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "application/json", resp.Data[logical.HTTPContentType])
+
+			var body map[string]any
+			assert.NoError(t, json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &body))
+			return body
+		}
+
+		assert.Equal(t, map[string]any{"label": "Vault"}, request(logical.ReadOperation, nil))
+
+		body := request(logical.UpdateOperation, map[string]any{"account": wallet.PublicKey().String()})
+		assert.Equal(t, id, body["state"])
+
+		sig, err := wallet.PrivateKey.Sign([]byte(body["data"].(string)))
+		assert.NoError(t, err)
+
+		body = request(logical.UpdateOperation, map[string]any{
+			"account":   wallet.PublicKey().String(),
+			"data":      body["data"],
+			"state":     body["state"],
+			"signature": sig.String(),
+		})
+		assert.NotEmpty(t, body["message"])
+
+		resp, err = poll(id, pollToken)
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+
+	t.Run("Bind Session to Public Key", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := createSession(nil)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "missing or empty public key")
+
+		resp, err = createSession(map[string]any{"public_key": wallet.PublicKey().String()})
+		assert.NoError(t, err)
+
+		id := resp.Data["session_id"].(string)
+		challenge := resp.Data["nonce"].(string)
+
+		resp, err = signSession(other, id, challenge)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "public key mismatch")
+
+		resp, err = signSession(wallet, id, "another challenge")
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), loginFailedMessage)
+	})
+
+	t.Run("Apply Role on Poll", func(t *testing.T) {
+		t.Helper()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/session",
+			Storage:   storage,
+			Data: map[string]any{
+				"bound_public_keys": wallet.PublicKey().String(),
+				"token_policies":    "session",
+			},
+		})
+		assert.NoError(t, err)
+
+		resp, err := createSession(map[string]any{"public_key": other.PublicKey().String(), "role": "session"})
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "invalid role or public key")
+
+		resp, err = createSession(map[string]any{"public_key": wallet.PublicKey().String(), "role": "session"})
+		assert.NoError(t, err)
+
+		id := resp.Data["session_id"].(string)
+		pollToken := resp.Data["poll_token"].(string)
+		challenge := resp.Data["nonce"].(string)
+
+		_, err = signSession(wallet, id, challenge)
+		assert.NoError(t, err)
+
+		resp, err = poll(id, pollToken)
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Auth.Policies, "session")
+	})
+
+	t.Run("Reject Roles that no longer Support Sessions", func(t *testing.T) {
+		t.Helper()
+
+		writeRole := func(data map[string]any) {
+			resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "role/session",
+				Storage:   storage,
+				Data:      data,
+			})
+			assert.NoError(t, err)
+			assert.Nil(t, resp)
+		}
+
+		for _, data := range []map[string]any{
+			{"cosigner_public_keys": other.PublicKey().String()},
+			{"bound_public_keys": []string{wallet.PublicKey().String(), other.PublicKey().String()}, "signature_threshold": 2},
+		} {
+			unsigned, err := createSession(map[string]any{"public_key": wallet.PublicKey().String(), "role": "session"})
+			assert.NoError(t, err)
+
+			signed, err := createSession(map[string]any{"public_key": wallet.PublicKey().String(), "role": "session"})
+			assert.NoError(t, err)
+
+			resp, err := signSession(wallet, signed.Data["session_id"].(string), signed.Data["nonce"].(string))
+			assert.NoError(t, err)
+			assert.Nil(t, resp)
+
+			writeRole(data)
+
+			resp, err = signSession(wallet, unsigned.Data["session_id"].(string), unsigned.Data["nonce"].(string))
+			assert.NoError(t, err)
+			assert.ErrorContains(t, resp.Error(), errSessionRoleUnsupported, data)
+
+			resp, err = poll(signed.Data["session_id"].(string), signed.Data["poll_token"].(string))
+			assert.NoError(t, err)
+			assert.ErrorContains(t, resp.Error(), errSessionRoleUnsupported, data)
+			assert.Nil(t, resp.Auth)
+
+			writeRole(map[string]any{
+				"bound_public_keys":    wallet.PublicKey().String(),
+				"cosigner_public_keys": "",
+				"signature_threshold":  0,
+			})
+		}
+	})
+
+	t.Run("Expire Sessions", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := createSession(map[string]any{"public_key": wallet.PublicKey().String()})
+		assert.NoError(t, err)
+
+		id := resp.Data["session_id"].(string)
+		pollToken := resp.Data["poll_token"].(string)
+
+		session, err := getLoginSession(context.Background(), storage, id)
+		assert.NoError(t, err)
+
+		session.ExpiresAt = session.IssuedAt - 1
+		assert.NoError(t, putLoginSession(context.Background(), storage, session))
+
+		resp, err = poll(id, pollToken)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "session expired")
+
+		session, err = getLoginSession(context.Background(), storage, id)
+		assert.NoError(t, err)
+		assert.Nil(t, session)
+	})
+}
@@ -2,21 +2,27 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/callensm/vault-plugin-solana/internal/message"
+	"github.com/callensm/vault-plugin-solana/internal/offchain"
 )
 
 func TestOffchainMessageSigningAuthentication(t *testing.T) {
 	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
 
 	wallet := solana.NewWallet()
 
-	var nonce string
+	var nonce, nonceID string
 	var signature solana.Signature
 	var err error
 
@@ -36,6 +42,7 @@ func TestOffchainMessageSigningAuthentication(t *testing.T) {
 		assert.Contains(t, resp.Data, "nonce")
 
 		nonce = resp.Data["nonce"].(string)
+		nonceID = resp.Data["nonce_id"].(string)
 	})
 
 	t.Run("Sign Offchain Message", func(t *testing.T) {
@@ -59,6 +66,7 @@ func TestOffchainMessageSigningAuthentication(t *testing.T) {
 			Storage:   storage,
 			Data: map[string]any{
 				"public_key": wallet.PublicKey().String(),
+				"nonce_id":   nonceID,
 				"signature":  signature.String(),
 			},
 		})
@@ -68,3 +76,1052 @@ func TestOffchainMessageSigningAuthentication(t *testing.T) {
 		assert.NotNil(t, resp.Auth)
 	})
 }
+
+func TestConcurrentNonces(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]any{
+			"max_nonces_per_key": 2,
+		},
+	})
+	assert.NoError(t, err)
+
+	requestNonce := func() (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+			},
+		})
+	}
+
+	first, err := requestNonce()
+	assert.NoError(t, err)
+
+	second, err := requestNonce()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Data["nonce_id"], second.Data["nonce_id"])
+
+	login := func(nonce *logical.Response) (*logical.Response, error) {
+		msg := message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			MessageBody: []byte(nonce.Data["nonce"].(string)),
+			Version:     0,
+		})
+
+		signature, err := wallet.PrivateKey.Sign(msg)
+		assert.NoError(t, err)
+
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"nonce_id":   nonce.Data["nonce_id"].(string),
+				"signature":  signature.String(),
+			},
+		})
+	}
+
+	t.Run("Evict Oldest Nonce beyond Limit", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := requestNonce()
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+
+		resp, err = login(first)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "nonce not found")
+	})
+
+	t.Run("Login with Earlier Nonce", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(second)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+
+		resp, err = requestNonce()
+
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+	})
+}
+
+func TestStatelessNonces(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]any{
+			"stateless_nonces": true,
+		},
+	})
+	assert.NoError(t, err)
+
+	resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "nonce",
+		Storage:   storage,
+		Data: map[string]any{
+			"public_key": wallet.PublicKey().String(),
+		},
+	})
+	assert.NoError(t, err)
+
+	nonceID := resp.Data["nonce_id"].(string)
+
+	keys, err := storage.List(context.Background(), nonceStoragePrefix)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	msg := message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+		MessageBody: []byte(resp.Data["nonce"].(string)),
+		Version:     0,
+	})
+
+	signature, err := wallet.PrivateKey.Sign(msg)
+	assert.NoError(t, err)
+
+	login := func(id string) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"nonce_id":   id,
+				"signature":  signature.String(),
+			},
+		})
+	}
+
+	t.Run("Reject Tampered Nonce Token", func(t *testing.T) {
+		t.Helper()
+
+		payload, mac, _ := strings.Cut(nonceID, ".")
+		tampered := payload[:len(payload)-2] + "AA" + "." + mac
+
+		resp, err := login(tampered)
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Login with Stateless Nonce", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(nonceID)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+
+	t.Run("Reject Replayed Nonce", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(nonceID)
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Prune Expired Redeemed Nonces", func(t *testing.T) {
+		t.Helper()
+
+		cache := &backend.usedNonces
+		now := time.Now().Unix()
+
+		ok, err := cache.markUsed("expired", now-1)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.NoError(t, backend.periodicFunc(context.Background(), &logical.Request{Storage: storage}))
+
+		cache.Lock()
+		_, expired := cache.entries["expired"]
+		assert.False(t, expired)
+		assert.Len(t, cache.entries, 1)
+		cache.Unlock()
+	})
+
+	t.Run("Bound Redeemed Nonces", func(t *testing.T) {
+		t.Helper()
+
+		cache := &usedNonceCache{entries: make(map[string]int64, maxUsedNonces)}
+		expiresAt := time.Now().Add(time.Minute).Unix()
+
+		for i := range maxUsedNonces {
+			cache.entries[strconv.Itoa(i)] = expiresAt
+		}
+
+		_, err := cache.markUsed("another", expiresAt)
+		assert.ErrorIs(t, err, errUsedNonceCacheFull)
+
+		cache.entries["0"] = 0
+
+		ok, err := cache.markUsed("another", expiresAt)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("Sign with a Cluster-Local Key", func(t *testing.T) {
+		t.Helper()
+
+		assert.Contains(t, backend.SpecialPaths().LocalStorage, nonceKeyStorageKey)
+
+		requestNonce := func() *logical.Response {
+			resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "nonce",
+				Storage:   storage,
+				Data: map[string]any{
+					"public_key": wallet.PublicKey().String(),
+				},
+			})
+			assert.NoError(t, err)
+			assert.False(t, resp.IsError())
+
+			return resp
+		}
+
+		issued := requestNonce()
+
+		// A cluster the mount is replicated to starts without a key.
+		assert.NoError(t, storage.Delete(context.Background(), nonceKeyStorageKey))
+		requestNonce()
+
+		key, err := backend.getNonceKey(context.Background(), storage)
+		assert.NoError(t, err)
+		assert.Len(t, key, 32)
+
+		// Nonces issued with another cluster's key are not accepted.
+		signature, err := wallet.PrivateKey.Sign(message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			MessageBody: []byte(issued.Data["nonce"].(string)),
+			Version:     0,
+		}))
+		assert.NoError(t, err)
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"nonce_id":   issued.Data["nonce_id"].(string),
+				"signature":  signature.String(),
+			},
+		})
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "nonce not found")
+	})
+}
+
+func TestSignInWithSolana(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+
+	t.Run("Reject Unconfigured Mode", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"mode":       "siws",
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]any{
+			"siws_domain":    "vault.example.com",
+			"siws_statement": "Sign in to Vault",
+			"siws_uri":       "https://vault.example.com",
+			"siws_chain_id":  "mainnet",
+		},
+	})
+	assert.NoError(t, err)
+
+	requestInput := func() (string, *message.SignInInput) {
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"mode":       "siws",
+			},
+		})
+		assert.NoError(t, err)
+
+		return resp.Data["nonce_id"].(string), resp.Data["siws_input"].(*message.SignInInput)
+	}
+
+	login := func(nonceID, text string) (*logical.Response, error) {
+		signature, err := wallet.PrivateKey.Sign([]byte(text))
+		assert.NoError(t, err)
+
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key":     wallet.PublicKey().String(),
+				"nonce_id":       nonceID,
+				"signed_message": text,
+				"signature":      signature.String(),
+			},
+		})
+	}
+
+	t.Run("Reject Altered Domain", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, input := requestInput()
+		input.Domain = "evil.example.com"
+
+		resp, err := login(nonceID, message.CreateSignInMessage(input))
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Keep Issued Input after Config Change", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, input := requestInput()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"siws_statement": "Sign in to Vault again",
+			},
+		})
+		assert.NoError(t, err)
+
+		resp, err := login(nonceID, message.CreateSignInMessage(input))
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+
+	t.Run("Login with Signed Message", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, input := requestInput()
+		assert.Equal(t, "Sign in to Vault again", input.Statement)
+		assert.Equal(t, wallet.PublicKey().String(), input.Address)
+		assert.Equal(t, "mainnet", input.ChainID)
+
+		resp, err := login(nonceID, message.CreateSignInMessage(input))
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+}
+
+func TestTransactionLogin(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+
+	requestNonce := func() (string, string) {
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"mode":       "transaction",
+			},
+		})
+		assert.NoError(t, err)
+
+		return resp.Data["nonce_id"].(string), resp.Data["nonce"].(string)
+	}
+
+	signMemo := func(memo string, blockhash solana.Hash) string {
+		tx, err := solana.NewTransaction(
+			[]solana.Instruction{
+				solana.NewInstruction(solana.MemoProgramID, solana.AccountMetaSlice{}, []byte(memo)),
+			},
+			blockhash,
+			solana.TransactionPayer(wallet.PublicKey()),
+		)
+		assert.NoError(t, err)
+
+		_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+			return &wallet.PrivateKey
+		})
+		assert.NoError(t, err)
+
+		encoded, err := tx.ToBase64()
+		assert.NoError(t, err)
+
+		return encoded
+	}
+
+	login := func(nonceID, transaction string) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key":  wallet.PublicKey().String(),
+				"nonce_id":    nonceID,
+				"transaction": transaction,
+			},
+		})
+	}
+
+	t.Run("Reject Real Blockhash", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, nonce := requestNonce()
+
+		resp, err := login(nonceID, signMemo(nonce, solana.Hash(solana.NewWallet().PublicKey())))
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Reject Mismatched Memo", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, _ := requestNonce()
+
+		resp, err := login(nonceID, signMemo("something else", solana.Hash{}))
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Login with Signed Memo Transaction", func(t *testing.T) {
+		t.Helper()
+
+		nonceID, nonce := requestNonce()
+
+		resp, err := login(nonceID, signMemo(nonce, solana.Hash{}))
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+}
+
+func TestLoginAliasAndRenewal(t *testing.T) {
+	backend, storage := getTestBackend(t)
+
+	wallet := solana.NewWallet()
+	other := solana.NewWallet()
+
+	writeRole := func(data map[string]any) {
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/test",
+			Storage:   storage,
+			Data:      data,
+		})
+		assert.NoError(t, err)
+	}
+
+	writeRole(map[string]any{
+		"bound_public_keys": wallet.PublicKey().String(),
+		"token_policies":    "admin",
+		"token_ttl":         "1h",
+	})
+
+	resp, err := loginWithWallet(t, backend, storage, wallet, map[string]any{"role": "test"})
+	assert.NoError(t, err)
+
+	auth := resp.Auth
+	auth.TokenPolicies = auth.Policies
+
+	renew := func() (*logical.Response, error) {
+		req := logical.RenewAuthRequest("login", auth, nil)
+		req.Storage = storage
+		return backend.HandleRequest(context.Background(), req)
+	}
+
+	t.Run("Populate Identity Alias", func(t *testing.T) {
+		t.Helper()
+
+		assert.Equal(t, wallet.PublicKey().String(), auth.Alias.Name)
+		assert.Equal(t, "test", auth.Alias.Metadata["role"])
+	})
+
+	t.Run("Renew with Updated TTL", func(t *testing.T) {
+		t.Helper()
+
+		writeRole(map[string]any{"token_ttl": "2h"})
+
+		resp, err := renew()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2*time.Hour, resp.Auth.TTL)
+	})
+
+	t.Run("Deny Renewal when Policies Change", func(t *testing.T) {
+		t.Helper()
+
+		writeRole(map[string]any{"token_policies": "admin,extra"})
+
+		resp, err := renew()
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+
+		writeRole(map[string]any{"token_policies": "admin"})
+	})
+
+	t.Run("Deny Renewal when Key is Unbound", func(t *testing.T) {
+		t.Helper()
+
+		writeRole(map[string]any{"bound_public_keys": other.PublicKey().String()})
+
+		resp, err := renew()
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+}
+
+func TestChallengeTemplate(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+
+	writeConfig := func(data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	t.Run("Reject Template Without Nonce", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeConfig(map[string]any{
+			"nonce_template": "Sign in to {{.Audience}}",
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Reject Unknown Template Fields", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeConfig(map[string]any{
+			"nonce_template": "{{.Nonce}} {{.Unknown}}",
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	resp, err := writeConfig(map[string]any{
+		"audience":       "vault.example.com",
+		"nonce_template": "{{.Audience}} wants you to sign in to {{.Mount}}\nPublic Key: {{.PublicKey}}\nNonce: {{.Nonce}}\nIssued At: {{.IssuedAt}}\nExpires At: {{.ExpiresAt}}",
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	request := func(path string, data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       path,
+			Storage:    storage,
+			MountPoint: "auth/solana/",
+			Data:       data,
+		})
+	}
+
+	t.Run("Render and Verify Challenge", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := request("nonce", map[string]any{
+			"public_key": wallet.PublicKey().String(),
+		})
+		assert.NoError(t, err)
+
+		challenge := resp.Data["nonce"].(string)
+		assert.True(t, strings.HasPrefix(challenge, "vault.example.com wants you to sign in to auth/solana/\nPublic Key: "+wallet.PublicKey().String()+"\nNonce: "))
+		assert.Contains(t, challenge, "\nExpires At: ")
+
+		signature, err := wallet.PrivateKey.Sign(message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			Format:      message.FormatLimitedUTF8,
+			MessageBody: []byte(challenge),
+			Version:     0,
+		}))
+		assert.NoError(t, err)
+
+		resp, err = request("login", map[string]any{
+			"public_key": wallet.PublicKey().String(),
+			"nonce_id":   resp.Data["nonce_id"].(string),
+			"signature":  signature.String(),
+		})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+
+	t.Run("Reject Signature for Another Mount", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := request("nonce", map[string]any{
+			"public_key": wallet.PublicKey().String(),
+		})
+		assert.NoError(t, err)
+
+		challenge := strings.Replace(resp.Data["nonce"].(string), "auth/solana/", "auth/other/", 1)
+
+		signature, err := wallet.PrivateKey.Sign(message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			Format:      message.FormatLimitedUTF8,
+			MessageBody: []byte(challenge),
+			Version:     0,
+		}))
+		assert.NoError(t, err)
+
+		resp, err = request("login", map[string]any{
+			"public_key": wallet.PublicKey().String(),
+			"nonce_id":   resp.Data["nonce_id"].(string),
+			"signature":  signature.String(),
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Verify Outstanding Nonces after Template Change", func(t *testing.T) {
+		t.Helper()
+
+		for _, stateless := range []bool{false, true} {
+			_, err := writeConfig(map[string]any{
+				"audience":         "vault.example.com",
+				"nonce_template":   "{{.Audience}}: {{.Nonce}}",
+				"stateless_nonces": stateless,
+			})
+			assert.NoError(t, err)
+
+			resp, err := request("nonce", map[string]any{
+				"public_key": wallet.PublicKey().String(),
+			})
+			assert.NoError(t, err)
+
+			nonceID := resp.Data["nonce_id"].(string)
+			signature, err := wallet.PrivateKey.Sign(message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+				MessageBody: []byte(resp.Data["nonce"].(string)),
+				Version:     0,
+			}))
+			assert.NoError(t, err)
+
+			_, err = writeConfig(map[string]any{
+				"audience":       "other.example.com",
+				"nonce_template": "Sign in with {{.Nonce}}",
+			})
+			assert.NoError(t, err)
+
+			resp, err = request("login", map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"nonce_id":   nonceID,
+				"signature":  signature.String(),
+			})
+
+			assert.NoError(t, err)
+			assert.NotNil(t, resp.Auth, stateless)
+		}
+	})
+}
+
+func TestSignatureEncodings(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+
+	login := func(encode func(solana.Signature) string) (*logical.Response, error) {
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+			},
+		})
+		assert.NoError(t, err)
+
+		signature, err := wallet.PrivateKey.Sign(message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			MessageBody: []byte(resp.Data["nonce"].(string)),
+			Version:     0,
+		}))
+		assert.NoError(t, err)
+
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": wallet.PublicKey().String(),
+				"nonce_id":   resp.Data["nonce_id"].(string),
+				"signature":  encode(signature),
+			},
+		})
+	}
+
+	t.Run("Solana CLI JSON", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(func(sig solana.Signature) string {
+			return "{\n  \"signature\": \"" + sig.String() + "\"\n}"
+		})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+
+	t.Run("Base-64", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(func(sig solana.Signature) string {
+			return base64.StdEncoding.EncodeToString(sig[:])
+		})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+	})
+
+	t.Run("Reject Malformed Signature", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(func(sig solana.Signature) string {
+			return sig.String()[:40]
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Data["error"], "signature must be 64 bytes")
+	})
+}
+
+func TestDelegatedLogin(t *testing.T) {
+	backend, storage := getTestBackend(t)
+
+	cold := solana.NewWallet()
+	hot := solana.NewWallet()
+
+	const mount = "auth/solana/"
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]any{
+			"max_nonces_per_key": 20,
+			"nonce_rate_limit":   0,
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/operators",
+		Storage:   storage,
+		Data: map[string]any{
+			"bound_public_keys": cold.PublicKey().String(),
+			"token_ttl":         "8h",
+			"token_max_ttl":     "24h",
+		},
+	})
+	assert.NoError(t, err)
+
+	sign := func(wallet *solana.Wallet, body string) string {
+		msg, err := offchain.Message(body)
+		assert.NoError(t, err)
+
+		sig, err := wallet.PrivateKey.Sign(msg)
+		assert.NoError(t, err)
+		return sig.String()
+	}
+
+	certificate := func(delegate solana.PublicKey, mount string, expiresAt time.Time) string {
+		return message.CreateDelegationMessage(&message.Delegation{
+			Identity:  cold.PublicKey().String(),
+			Delegate:  delegate.String(),
+			Mount:     mount,
+			ExpiresAt: expiresAt,
+		})
+	}
+
+	login := func(signer *solana.Wallet, cert, certSig string) (*logical.Response, error) {
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "nonce",
+			Storage:    storage,
+			MountPoint: mount,
+			Data: map[string]any{
+				"public_key": cold.PublicKey().String(),
+				"role":       "operators",
+			},
+		})
+		assert.NoError(t, err)
+
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "login",
+			Storage:    storage,
+			MountPoint: mount,
+			Data: map[string]any{
+				"delegation":           cert,
+				"delegation_signature": certSig,
+				"nonce_id":             resp.Data["nonce_id"],
+				"public_key":           cold.PublicKey().String(),
+				"role":                 "operators",
+				"signature":            sign(signer, resp.Data["nonce"].(string)),
+			},
+		})
+	}
+
+	t.Run("Login as Identity with Delegate Key", func(t *testing.T) {
+		t.Helper()
+
+		cert := certificate(hot.PublicKey(), mount, time.Now().Add(2*time.Hour))
+
+		resp, err := login(hot, cert, sign(cold, cert))
+
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		assert.Equal(t, cold.PublicKey().String(), resp.Auth.Alias.Name)
+		assert.Equal(t, hot.PublicKey().String(), resp.Auth.Metadata["delegate"])
+		assert.InDelta(t, 2*time.Hour, resp.Auth.TTL, float64(time.Minute))
+		assert.InDelta(t, 2*time.Hour, resp.Auth.ExplicitMaxTTL, float64(time.Minute))
+	})
+
+	t.Run("Reject Nonce Signed by Identity Key", func(t *testing.T) {
+		t.Helper()
+
+		cert := certificate(hot.PublicKey(), mount, time.Now().Add(2*time.Hour))
+
+		resp, err := login(cold, cert, sign(cold, cert))
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), loginFailedMessage)
+	})
+
+	t.Run("Reject Certificate Signed by Delegate Key", func(t *testing.T) {
+		t.Helper()
+
+		cert := certificate(hot.PublicKey(), mount, time.Now().Add(2*time.Hour))
+
+		resp, err := login(hot, cert, sign(hot, cert))
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), loginFailedMessage)
+	})
+
+	t.Run("Reject Invalid Certificates", func(t *testing.T) {
+		t.Helper()
+
+		for reason, cert := range map[string]string{
+			"different mount": certificate(hot.PublicKey(), "auth/other/", time.Now().Add(time.Hour)),
+			"expired":         certificate(hot.PublicKey(), mount, time.Now().Add(-time.Minute)),
+			"malformed":       "let " + hot.PublicKey().String() + " sign in",
+		} {
+			resp, err := login(hot, cert, sign(cold, cert))
+
+			assert.NoError(t, err)
+			assert.ErrorContains(t, resp.Error(), "invalid delegation", reason)
+		}
+	})
+
+	t.Run("Deny Renewal after Delegation Expires", func(t *testing.T) {
+		t.Helper()
+
+		cert := certificate(hot.PublicKey(), mount, time.Now().Add(2*time.Second))
+
+		resp, err := login(hot, cert, sign(cold, cert))
+		assert.NoError(t, err)
+
+		auth := resp.Auth
+		auth.TokenPolicies = auth.Policies
+
+		renew := func() (*logical.Response, error) {
+			req := logical.RenewAuthRequest("login", auth, nil)
+			req.Storage = storage
+			return backend.HandleRequest(context.Background(), req)
+		}
+
+		resp, err = renew()
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+		assert.LessOrEqual(t, resp.Auth.TTL, 2*time.Second)
+
+		auth.InternalData["delegation_expires_at"] = time.Now().Add(-time.Second).Format(time.RFC3339)
+
+		resp, err = renew()
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "delegation expired")
+	})
+}
+
+func TestCosignedLogin(t *testing.T) {
+	backend, storage := getTestBackend(t)
+
+	user := solana.NewWallet()
+	approver := solana.NewWallet()
+	other := solana.NewWallet()
+
+	writeRole := func(data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/admin",
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	sign := func(wallet *solana.Wallet, body string) string {
+		sig, err := wallet.PrivateKey.Sign(message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			MessageBody: []byte(body),
+			Version:     0,
+		}))
+		assert.NoError(t, err)
+		return sig.String()
+	}
+
+	login := func(cosigner *solana.Wallet, cosign func(challenge string) string) (*logical.Response, error) {
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": user.PublicKey().String(),
+				"role":       "admin",
+			},
+		})
+		assert.NoError(t, err)
+
+		challenge := resp.Data["nonce"].(string)
+
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"cosigner_public_key": cosigner.PublicKey().String(),
+				"cosigner_signature":  cosign(challenge),
+				"nonce_id":            resp.Data["nonce_id"],
+				"public_key":          user.PublicKey().String(),
+				"role":                "admin",
+				"signature":           sign(user, challenge),
+			},
+		})
+	}
+
+	resp, err := writeRole(map[string]any{
+		"bound_public_keys":    user.PublicKey().String(),
+		"cosigner_public_keys": approver.PublicKey().String(),
+		"token_policies":       "admin",
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	t.Run("Reject Invalid Co-Signer Keys", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := writeRole(map[string]any{"cosigner_public_keys": "not-a-key"})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Login with Co-Signature", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(approver, func(challenge string) string { return sign(approver, challenge) })
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+		assert.Equal(t, approver.PublicKey().String(), resp.Auth.Metadata["cosigner"])
+	})
+
+	t.Run("Require Co-Signature", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(approver, func(string) string { return "" })
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "requires a co-signer")
+	})
+
+	t.Run("Reject Unlisted Co-Signer", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(other, func(challenge string) string { return sign(other, challenge) })
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "not permitted by the role")
+	})
+
+	t.Run("Reject Co-Signature of Another Message", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(approver, func(string) string { return sign(approver, "approve anything") })
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), errCosignature.Error())
+	})
+
+	t.Run("Deny Renewal after Co-Signer is Removed", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := login(approver, func(challenge string) string { return sign(approver, challenge) })
+		assert.NoError(t, err)
+
+		auth := resp.Auth
+		auth.TokenPolicies = auth.Policies
+
+		_, err = writeRole(map[string]any{"cosigner_public_keys": other.PublicKey().String()})
+		assert.NoError(t, err)
+
+		req := logical.RenewAuthRequest("login", auth, nil)
+		req.Storage = storage
+
+		resp, err = backend.HandleRequest(context.Background(), req)
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "renewal denied")
+	})
+}
@@ -1,19 +1,29 @@
 package auth
 
 import (
+	"cmp"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mr-tron/base58"
 )
 
 const (
-	nonceFormat        = "vault:solana:%s"
-	nonceStorageFormat = "nonce/%s"
+	loginModeOffchain    = "offchain"
+	loginModeSIWS        = "siws"
+	loginModeTransaction = "transaction"
+
+	maxNonceTTL        = time.Hour
+	nonceStoragePrefix = "nonce/"
+	nonceStorageFormat = nonceStoragePrefix + "%s/%s"
 )
 
 func pathNonce(s *SolanaAuthBackend) *framework.Path {
@@ -25,6 +35,16 @@ func pathNonce(s *SolanaAuthBackend) *framework.Path {
 				Description: "The base-58 public key of the wallet to authenticate",
 				Required:    true,
 			},
+			"mode": {
+				Type:          framework.TypeString,
+				Description:   "The login mode the nonce will be signed with",
+				Default:       loginModeOffchain,
+				AllowedValues: []any{loginModeOffchain, loginModeSIWS, loginModeTransaction},
+			},
+			"role": {
+				Type:        framework.TypeString,
+				Description: "Name of the role to authenticate against",
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
@@ -36,37 +56,357 @@ func pathNonce(s *SolanaAuthBackend) *framework.Path {
 }
 
 func (s *SolanaAuthBackend) pathNonceUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := s.getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	var role *RoleEntry
+
+	roleName := data.Get("role").(string)
+	if roleName == "" && !config.AllowRolelessLogin {
+		return logical.ErrorResponse(errRoleRequired), nil
+	}
+
+	if roleName != "" {
+		role, err = s.getRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+
+		if role != nil && role.isMultisig() {
+			return s.issueMultisigNonce(ctx, req, data, config, roleName, role)
+		}
+	}
+
 	pubkey, ok := data.Get("public_key").(string)
 	if !ok || pubkey == "" {
 		return logical.ErrorResponse("missing or empty public key"), nil
 	}
 
+	if _, err := parsePublicKey(pubkey); err != nil {
+		return logical.ErrorResponse("invalid public key: %v", err), nil
+	}
+
+	if resp, err := s.limitNonceRequests(req, config, pubkey); resp != nil || err != nil {
+		return resp, err
+	}
+
+	mode := data.Get("mode").(string)
+	switch mode {
+	case loginModeOffchain, loginModeTransaction:
+	case loginModeSIWS:
+		if config.SIWSDomain == "" {
+			return logical.ErrorResponse("sign in with solana is not configured"), nil
+		}
+	default:
+		return logical.ErrorResponse("invalid login mode %q", mode), nil
+	}
+
+	if roleName != "" && (role == nil || !role.permitsPublicKey(pubkey)) {
+		return logical.ErrorResponse("invalid role or public key"), nil
+	}
+
+	var nonceKey []byte
+
+	if config.StatelessNonces {
+		nonceKey, err = s.ensureNonceKey(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		prefix := nonceStoragePrefix + pubkey + "/"
+
+		outstanding, err := s.pruneNonces(ctx, req.Storage, prefix, pubkey)
+		if err != nil {
+			return nil, err
+		}
+
+		// The oldest nonces are evicted rather than new ones refused, since
+		// anyone who knows the public key could otherwise lock it out.
+		if err := evictOldestNonces(ctx, req.Storage, prefix, outstanding, config.MaxNoncesPerKey-1); err != nil {
+			return nil, err
+		}
+
+		if len(outstanding) == 0 {
+			ok, err := s.pendingKeys.reserve(ctx, req.Storage, nonceStoragePrefix, config.MaxPendingPublicKeys)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				return logical.ErrorResponse("too many pending nonces, try again later"), logical.ErrRateLimitQuotaExceeded
+			}
+		}
+	}
+
+	nonce, ttl, err := newNonceEntry(config, role, roleName, pubkey, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// SIWS messages are built by the wallet from the sign in input rather
+	// than from the challenge template.
+	if mode == loginModeSIWS {
+		nonce.SignIn = signInInput(config, nonce)
+	} else {
+		if _, err := s.issueChallenge(ctx, req, config, nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	nonceID := nonce.ID
+
+	if config.StatelessNonces {
+		// The signed token carries the entire nonce, so nothing is written
+		// to storage until it is redeemed.
+		nonceID, err = signNonceToken(nonceKey, nonce)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		storageKey := fmt.Sprintf(nonceStorageFormat, pubkey, nonce.ID)
+		entry, err := logical.StorageEntryJSON(storageKey, nonce)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := req.Storage.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	respData := map[string]any{
+		"nonce":      nonce.Nonce,
+		"nonce_id":   nonceID,
+		"expires_at": nonce.ExpiresAt,
+		"ttl":        int64(ttl.Seconds()),
+	}
+
+	if mode == loginModeSIWS {
+		respData["siws_input"] = nonce.SignIn
+	} else {
+		respData["nonce"] = nonce.Challenge
+	}
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+// limitNonceRequests enforces the nonce rate limits of the public key, if
+// one is given, and of the client address, returning an error response if
+// either has been exceeded.
+func (s *SolanaAuthBackend) limitNonceRequests(req *logical.Request, config *AuthConfigEntry, pubkey string) (*logical.Response, error) {
+	if pubkey != "" && !s.nonceKeyLimiter.allow(pubkey, config.NonceRateLimit, config.NonceRateBurst) {
+		return logical.ErrorResponse("too many nonce requests for public key, try again later"), logical.ErrRateLimitQuotaExceeded
+	}
+
+	if req.Connection != nil && req.Connection.RemoteAddr != "" {
+		if !s.nonceAddrLimiter.allow(req.Connection.RemoteAddr, config.NonceClientRateLimit, config.NonceClientRateBurst) {
+			return logical.ErrorResponse("too many nonce requests from client address, try again later"), logical.ErrRateLimitQuotaExceeded
+		}
+	}
+
+	return nil, nil
+}
+
+// newNonceEntry generates a nonce for the public key to sign in the login
+// mode, returning it along with its lifetime.
+func newNonceEntry(config *AuthConfigEntry, role *RoleEntry, roleName, pubkey, mode string) (*NonceEntry, time.Duration, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to generate nonce id: %w", err)
+	}
+
 	nonceBytes := make([]byte, 32)
 	if _, err := rand.Read(nonceBytes); err != nil {
-		return nil, fmt.Errorf("failed to generate nonce bytes: %v", err)
+		return nil, 0, fmt.Errorf("failed to generate nonce bytes: %v", err)
 	}
 
-	nonceStr := base64.StdEncoding.EncodeToString(nonceBytes)
+	ttl, leeway := nonceWindow(config, role)
+
+	now := time.Now()
 	nonce := &NonceEntry{
-		ExpiresAt: time.Now().Add(5 * time.Minute).Unix(),
-		Nonce:     fmt.Sprintf(nonceFormat, nonceStr),
-		PublicKey: pubkey,
+		ID:              id,
+		ClockSkewLeeway: int64(leeway.Seconds()),
+		ExpiresAt:       now.Add(ttl).Unix(),
+		IssuedAt:        now.Unix(),
+		IssuedAtNano:    now.UnixNano(),
+		Mode:            mode,
+		Nonce:           base64.StdEncoding.EncodeToString(nonceBytes),
+		PublicKey:       pubkey,
+		Role:            roleName,
 	}
 
-	storageKey := fmt.Sprintf(nonceStorageFormat, pubkey)
-	entry, err := logical.StorageEntryJSON(storageKey, nonce)
+	// SIWS nonces must be alphanumeric.
+	if mode == loginModeSIWS {
+		nonce.Nonce = base58.Encode(nonceBytes)
+	}
+
+	return nonce, ttl, nil
+}
+
+// lookupNonce resolves a nonce identifier into its entry, either by
+// verifying a stateless nonce token or reading it from storage. A nil entry
+// is returned if the nonce does not exist or the token is invalid.
+func (s *SolanaAuthBackend) lookupNonce(ctx context.Context, store logical.Storage, config *AuthConfigEntry, pubkey, nonceID string) (*NonceEntry, error) {
+	if isNonceToken(nonceID) {
+		if !config.StatelessNonces {
+			return nil, nil
+		}
+
+		key, err := s.getNonceKey(ctx, store)
+		if err != nil || key == nil {
+			return nil, err
+		}
+
+		nonce, err := parseNonceToken(key, nonceID)
+		if err != nil {
+			return nil, nil
+		}
+
+		nonce.stateless = true
+		return nonce, nil
+	}
+
+	entry, err := store.Get(ctx, fmt.Sprintf(nonceStorageFormat, pubkey, nonceID))
 	if err != nil {
 		return nil, err
 	}
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	if entry == nil {
+		return nil, nil
+	}
+
+	var nonce NonceEntry
+	if err := entry.DecodeJSON(&nonce); err != nil {
 		return nil, err
 	}
 
-	return &logical.Response{
-		Data: map[string]any{
-			"nonce":      nonce.Nonce,
-			"expires_at": nonce.ExpiresAt,
-		},
-	}, nil
+	return &nonce, nil
+}
+
+// redeemNonce consumes the nonce so it cannot be used for another login,
+// returning false if it had already been redeemed.
+func (s *SolanaAuthBackend) redeemNonce(ctx context.Context, store logical.Storage, nonce *NonceEntry) (bool, error) {
+	if nonce.stateless {
+		return s.usedNonces.markUsed(nonce.ID, nonce.ExpiresAt+nonce.ClockSkewLeeway)
+	}
+
+	if err := s.deleteNonce(ctx, store, nonce); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *SolanaAuthBackend) deleteNonce(ctx context.Context, store logical.Storage, nonce *NonceEntry) error {
+	if nonce.stateless {
+		return nil
+	}
+
+	return store.Delete(ctx, fmt.Sprintf(nonceStorageFormat, nonce.PublicKey, nonce.ID))
+}
+
+// pruneNonces deletes the expired nonces stored beneath the prefix and
+// returns those still outstanding that were issued to the public key.
+func (s *SolanaAuthBackend) pruneNonces(ctx context.Context, store logical.Storage, prefix, pubkey string) ([]*NonceEntry, error) {
+	ids, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	var outstanding []*NonceEntry
+
+	for _, id := range ids {
+		entry, err := store.Get(ctx, prefix+id)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		var nonce NonceEntry
+		if err := entry.DecodeJSON(&nonce); err != nil {
+			return nil, err
+		}
+
+		if nonce.isExpired(now) {
+			if err := store.Delete(ctx, prefix+id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if nonce.PublicKey == pubkey {
+			outstanding = append(outstanding, &nonce)
+		}
+	}
+
+	return outstanding, nil
+}
+
+// evictOldestNonces deletes the earliest issued of the outstanding nonces
+// stored beneath the prefix until at most keep of them remain.
+func evictOldestNonces(ctx context.Context, store logical.Storage, prefix string, outstanding []*NonceEntry, keep int) error {
+	if len(outstanding) <= keep {
+		return nil
+	}
+
+	slices.SortStableFunc(outstanding, func(a, b *NonceEntry) int {
+		return cmp.Compare(a.IssuedAtNano, b.IssuedAtNano)
+	})
+
+	for _, nonce := range outstanding[:len(outstanding)-keep] {
+		if err := store.Delete(ctx, prefix+nonce.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isExpired reports whether the nonce can no longer be redeemed at the given
+// unix time, allowing for the clock skew leeway it was issued with.
+func (n *NonceEntry) isExpired(now int64) bool {
+	return now > n.ExpiresAt+n.ClockSkewLeeway
+}
+
+// nonceWindow returns the lifetime and clock skew leeway of nonces issued
+// for the role, falling back to the backend config for unset values.
+func nonceWindow(config *AuthConfigEntry, role *RoleEntry) (time.Duration, time.Duration) {
+	ttl, leeway := config.NonceTTL, config.ClockSkewLeeway
+
+	if role != nil {
+		if role.NonceTTL > 0 {
+			ttl = role.NonceTTL
+		}
+
+		if role.ClockSkewLeeway > 0 {
+			leeway = role.ClockSkewLeeway
+		}
+	}
+
+	return ttl, leeway
+}
+
+func validateNonceWindow(ttl, leeway, tokenMaxTTL time.Duration) error {
+	switch {
+	case ttl <= 0:
+		return errors.New("nonce_ttl must be positive")
+	case ttl > maxNonceTTL:
+		return fmt.Errorf("nonce_ttl cannot exceed %s", maxNonceTTL)
+	case leeway < 0:
+		return errors.New("clock_skew_leeway cannot be negative")
+	case leeway > ttl:
+		return errors.New("clock_skew_leeway cannot exceed nonce_ttl")
+	case tokenMaxTTL > 0 && ttl+leeway > tokenMaxTTL:
+		return errors.New("nonce_ttl and clock_skew_leeway combined cannot exceed token_max_ttl")
+	}
+
+	return nil
 }
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNonceAbuseProtection(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	requestNonce := func(pubkey, addr string) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Connection: &logical.Connection{
+				RemoteAddr: addr,
+			},
+			Data: map[string]any{
+				"public_key": pubkey,
+			},
+		})
+	}
+
+	t.Run("Reject Invalid Public Keys", func(t *testing.T) {
+		t.Helper()
+
+		pda, _, err := solana.FindProgramAddress([][]byte{[]byte("vault")}, solana.SystemProgramID)
+		assert.NoError(t, err)
+
+		for _, pubkey := range []string{"not-a-key", "1111", pda.String()} {
+			resp, err := requestNonce(pubkey, "127.0.0.1")
+
+			assert.NoError(t, err)
+			assert.True(t, resp.IsError(), pubkey)
+		}
+	})
+
+	t.Run("Rate Limit Public Key", func(t *testing.T) {
+		t.Helper()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"max_nonces_per_key": 10,
+				"nonce_rate_limit":   0.001,
+				"nonce_rate_burst":   2,
+			},
+		})
+		assert.NoError(t, err)
+
+		wallet := solana.NewWallet()
+
+		for i, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+			_, err := requestNonce(wallet.PublicKey().String(), addr)
+			if i < 2 {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, logical.ErrRateLimitQuotaExceeded)
+			}
+		}
+	})
+
+	t.Run("Rate Limit Client Address", func(t *testing.T) {
+		t.Helper()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"nonce_client_rate_limit": 0.001,
+				"nonce_client_rate_burst": 2,
+			},
+		})
+		assert.NoError(t, err)
+
+		for i := range 3 {
+			_, err := requestNonce(solana.NewWallet().PublicKey().String(), "10.0.0.4")
+			if i < 2 {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, logical.ErrRateLimitQuotaExceeded)
+			}
+		}
+	})
+
+	t.Run("Limit Pending Public Keys", func(t *testing.T) {
+		t.Helper()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]any{
+				"max_pending_public_keys": 3,
+				"nonce_client_rate_limit": 0,
+				"nonce_rate_limit":        0,
+			},
+		})
+		assert.NoError(t, err)
+
+		pending, err := storage.List(context.Background(), nonceStoragePrefix)
+		assert.NoError(t, err)
+		assert.Len(t, pending, 3)
+
+		_, err = requestNonce(solana.NewWallet().PublicKey().String(), "10.0.0.5")
+		assert.ErrorIs(t, err, logical.ErrRateLimitQuotaExceeded)
+
+		// Keys whose nonces are removed are only released once recounted.
+		keys, err := storage.List(context.Background(), nonceStoragePrefix+pending[0])
+		assert.NoError(t, err)
+		for _, key := range keys {
+			assert.NoError(t, storage.Delete(context.Background(), nonceStoragePrefix+pending[0]+key))
+		}
+
+		_, err = requestNonce(solana.NewWallet().PublicKey().String(), "10.0.0.5")
+		assert.ErrorIs(t, err, logical.ErrRateLimitQuotaExceeded)
+
+		assert.NoError(t, backend.periodicFunc(context.Background(), &logical.Request{Storage: storage}))
+
+		resp, err := requestNonce(solana.NewWallet().PublicKey().String(), "10.0.0.5")
+		assert.NoError(t, err)
+		assert.False(t, resp.IsError())
+	})
+}
+
+func TestNonceWindow(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	wallet := solana.NewWallet()
+
+	request := func(path string, data map[string]any) (*logical.Response, error) {
+		return backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	t.Run("Reject Invalid Windows", func(t *testing.T) {
+		t.Helper()
+
+		for _, data := range []map[string]any{
+			{"nonce_ttl": "2h"},
+			{"nonce_ttl": "1m", "clock_skew_leeway": "2m"},
+			{"nonce_ttl": "30m", "clock_skew_leeway": "30m", "token_max_ttl": "45m"},
+		} {
+			resp, err := request("config", data)
+
+			assert.NoError(t, err)
+			assert.True(t, resp.IsError(), data)
+		}
+
+		resp, err := request("role/short", map[string]any{
+			"bound_public_keys": wallet.PublicKey().String(),
+			"nonce_ttl":         "10m",
+			"token_max_ttl":     "5m",
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Default Expiry", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := request("nonce", map[string]any{
+			"public_key": wallet.PublicKey().String(),
+		})
+
+		assert.NoError(t, err)
+		assert.EqualValues(t, 300, resp.Data["ttl"])
+		assert.InDelta(t, time.Now().Add(5*time.Minute).Unix(), resp.Data["expires_at"], 2)
+	})
+
+	t.Run("Role Overrides Config", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := request("role/hsm", map[string]any{
+			"bound_public_keys": wallet.PublicKey().String(),
+			"nonce_ttl":         "30m",
+			"clock_skew_leeway": "1m",
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		resp, err = request("nonce", map[string]any{
+			"public_key": wallet.PublicKey().String(),
+			"role":       "hsm",
+		})
+
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1800, resp.Data["ttl"])
+		assert.InDelta(t, time.Now().Add(30*time.Minute).Unix(), resp.Data["expires_at"], 2)
+	})
+
+	t.Run("Accept Within Leeway", func(t *testing.T) {
+		t.Helper()
+
+		nonce := &NonceEntry{ExpiresAt: 100, ClockSkewLeeway: 30}
+
+		assert.False(t, nonce.isExpired(100))
+		assert.False(t, nonce.isExpired(130))
+		assert.True(t, nonce.isExpired(131))
+	})
+}
@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/tokenutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	roleStoragePrefix = "role/"
+)
+
+func pathRole(s *SolanaAuthBackend) []*framework.Path {
+	fields := map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: "Name of the role",
+		},
+		"bound_public_keys": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "Comma-separated list of base-58 public keys permitted to login with the role",
+		},
+		"clock_skew_leeway": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Additional duration past the expiry of a nonce during which it is still accepted; if unset, the backend config value is used",
+		},
+		"cosigner_public_keys": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "Comma-separated list of base-58 approver public keys, one of which must co-sign every login with the role",
+		},
+		"nonce_ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Duration for which a nonce issued for the role may be used to login; if unset, the backend config value is used",
+		},
+		"signature_threshold": {
+			Type:        framework.TypeInt,
+			Description: "Number of distinct bound public keys that must sign a single nonce to login as the role; 0 lets each bound key login on its own",
+		},
+	}
+	addOnChainBindingFields(fields)
+	tokenutil.AddTokenFields(fields)
+
+	return []*framework.Path{
+		{
+			Pattern: "role/" + framework.GenericNameRegex("name"),
+			Fields:  fields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: s.pathRoleWrite,
+					Summary:  "Create a role",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: s.pathRoleRead,
+					Summary:  "Read a role",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: s.pathRoleWrite,
+					Summary:  "Update a role",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: s.pathRoleDelete,
+					Summary:  "Delete a role",
+				},
+			},
+			ExistenceCheck: s.pathRoleExistenceCheck,
+		},
+		{
+			Pattern: "role/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: s.pathRoleList,
+					Summary:  "List all role names",
+				},
+			},
+		},
+	}
+}
+
+func (s *SolanaAuthBackend) pathRoleDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing role name"), nil
+	}
+
+	if err := req.Storage.Delete(ctx, roleStoragePrefix+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (s *SolanaAuthBackend) pathRoleExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	name := data.Get("name").(string)
+	entry, err := s.getRole(ctx, req.Storage, name)
+	if err != nil {
+		return false, err
+	}
+	return entry != nil, nil
+}
+
+func (s *SolanaAuthBackend) pathRoleList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, roleStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (s *SolanaAuthBackend) pathRoleRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing role name"), nil
+	}
+
+	role, err := s.getRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return nil, nil
+	}
+
+	respData := map[string]any{
+		"bound_public_keys":    role.BoundPublicKeys,
+		"clock_skew_leeway":    int64(role.ClockSkewLeeway.Seconds()),
+		"cosigner_public_keys": role.CosignerPublicKeys,
+		"nonce_ttl":            int64(role.NonceTTL.Seconds()),
+		"signature_threshold":  role.SignatureThreshold,
+	}
+	role.populateOnChainBindings(respData)
+	role.PopulateTokenData(respData)
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+func (s *SolanaAuthBackend) pathRoleWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing role name"), nil
+	}
+
+	role, err := s.getRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		role = &RoleEntry{
+			TokenParams: tokenutil.TokenParams{
+				TokenTTL:    defaultTokenTtl,
+				TokenMaxTTL: defaultTokenMaxTtl,
+			},
+		}
+	}
+
+	if raw, ok := data.GetOk("bound_public_keys"); ok {
+		role.BoundPublicKeys = raw.([]string)
+	}
+
+	if raw, ok := data.GetOk("clock_skew_leeway"); ok {
+		role.ClockSkewLeeway = time.Duration(raw.(int)) * time.Second
+	}
+
+	if raw, ok := data.GetOk("cosigner_public_keys"); ok {
+		role.CosignerPublicKeys = raw.([]string)
+	}
+
+	if raw, ok := data.GetOk("nonce_ttl"); ok {
+		role.NonceTTL = time.Duration(raw.(int)) * time.Second
+	}
+
+	if raw, ok := data.GetOk("signature_threshold"); ok {
+		role.SignatureThreshold = raw.(int)
+	}
+
+	if err := role.ParseTokenFields(req, data); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if err := role.parseOnChainBindings(data); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if role.ClockSkewLeeway < 0 || role.NonceTTL < 0 {
+		return logical.ErrorResponse("nonce_ttl and clock_skew_leeway cannot be negative"), nil
+	}
+
+	config, err := s.getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, leeway := nonceWindow(config, role)
+	if err := validateNonceWindow(ttl, leeway, role.TokenMaxTTL); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if len(role.BoundPublicKeys) == 0 && !role.hasOnChainBindings() {
+		return logical.ErrorResponse("at least one bound public key or on-chain binding is required"), nil
+	}
+
+	for _, key := range role.BoundPublicKeys {
+		if _, err := parsePublicKey(key); err != nil {
+			return logical.ErrorResponse("invalid bound public key %q: %v", key, err), nil
+		}
+	}
+
+	for _, key := range role.CosignerPublicKeys {
+		if _, err := parsePublicKey(key); err != nil {
+			return logical.ErrorResponse("invalid co-signer public key %q: %v", key, err), nil
+		}
+	}
+
+	if err := role.validateSignatureThreshold(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if role.isMultisig() && role.requiresCosigner() {
+		return logical.ErrorResponse("cosigner_public_keys cannot be combined with signature_threshold"), nil
+	}
+
+	if err := s.setRole(ctx, req.Storage, name, role); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (s *SolanaAuthBackend) getRole(ctx context.Context, store logical.Storage, name string) (*RoleEntry, error) {
+	entry, err := store.Get(ctx, roleStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var role RoleEntry
+	if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+
+	upgradeTokenParams(&role.TokenParams)
+
+	return &role, nil
+}
+
+func (s *SolanaAuthBackend) setRole(ctx context.Context, store logical.Storage, name string, role *RoleEntry) error {
+	entry, err := logical.StorageEntryJSON(roleStoragePrefix+name, role)
+	if err != nil {
+		return err
+	}
+
+	return store.Put(ctx, entry)
+}
+
+// permitsPublicKey reports whether the public key may attempt to login with
+// the role. Roles without bound public keys admit any key that satisfies
+// their on-chain bindings.
+func (r *RoleEntry) permitsPublicKey(pubkey string) bool {
+	if len(r.BoundPublicKeys) == 0 {
+		return r.hasOnChainBindings()
+	}
+
+	return slices.Contains(r.BoundPublicKeys, pubkey)
+}
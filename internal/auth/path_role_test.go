@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+)
+
+func TestRoleManagement(t *testing.T) {
+	backend, storage := getTestBackend(t)
+
+	wallet := solana.NewWallet()
+
+	t.Run("Create Role", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "role/test",
+			Storage:   storage,
+			Data: map[string]any{
+				"bound_public_keys": wallet.PublicKey().String(),
+				"token_policies":    "admin",
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("Reject Invalid Bound Public Key", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "role/invalid",
+			Storage:   storage,
+			Data: map[string]any{
+				"bound_public_keys": "not-a-key",
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Read Role", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "role/test",
+			Storage:   storage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{wallet.PublicKey().String()}, resp.Data["bound_public_keys"])
+		assert.Equal(t, []string{"admin"}, resp.Data["token_policies"])
+	})
+
+	t.Run("List Roles", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "role/",
+			Storage:   storage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"test"}, resp.Data["keys"])
+	})
+
+	t.Run("Delete Role", func(t *testing.T) {
+		t.Helper()
+
+		_, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "role/test",
+			Storage:   storage,
+		})
+		assert.NoError(t, err)
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "role/test",
+			Storage:   storage,
+		})
+
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+}
+
+func TestRoleBoundLogin(t *testing.T) {
+	backend, storage := getTestBackend(t)
+
+	bound := solana.NewWallet()
+	unbound := solana.NewWallet()
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/test",
+		Storage:   storage,
+		Data: map[string]any{
+			"bound_public_keys": bound.PublicKey().String(),
+			"token_policies":    "admin",
+		},
+	})
+	assert.NoError(t, err)
+
+	t.Run("Reject Nonce for Unbound Public Key", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": unbound.PublicKey().String(),
+				"role":       "test",
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("Login with Bound Public Key", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "nonce",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": bound.PublicKey().String(),
+				"role":       "test",
+			},
+		})
+		assert.NoError(t, err)
+
+		msg := message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			MessageBody: []byte(resp.Data["nonce"].(string)),
+			Version:     0,
+		})
+
+		signature, err := bound.PrivateKey.Sign(msg)
+		assert.NoError(t, err)
+
+		resp, err = backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Data: map[string]any{
+				"public_key": bound.PublicKey().String(),
+				"nonce_id":   resp.Data["nonce_id"].(string),
+				"role":       "test",
+				"signature":  signature.String(),
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+		assert.Equal(t, []string{"admin"}, resp.Auth.Policies)
+	})
+
+	t.Run("Require Role by Default", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := loginWithWallet(t, backend, storage, unbound, nil)
+
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "missing role")
+	})
+
+	t.Run("Deny Renewal once Role-less Login is Disallowed", func(t *testing.T) {
+		t.Helper()
+
+		setRoleless := func(allow bool) {
+			_, err := backend.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Storage:   storage,
+				Data: map[string]any{
+					"allow_roleless_login": allow,
+				},
+			})
+			assert.NoError(t, err)
+		}
+
+		setRoleless(true)
+
+		resp, err := loginWithWallet(t, backend, storage, unbound, nil)
+		assert.NoError(t, err)
+		assert.NotNil(t, resp.Auth)
+
+		setRoleless(false)
+
+		auth := resp.Auth
+		auth.TokenPolicies = auth.Policies
+
+		req := logical.RenewAuthRequest("login", auth, nil)
+		req.Storage = storage
+
+		resp, err = backend.HandleRequest(context.Background(), req)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, resp.Error(), "renewal denied")
+	})
+}
@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	tidyInterval = 5 * time.Minute
+)
+
+type tidyStatus struct {
+	sync.RWMutex
+
+	running        bool
+	lastRunStart   time.Time
+	lastRunEnd     time.Time
+	noncesChecked  int
+	noncesDeleted  int
+	lockoutsPruned int
+	totalDeleted   int
+	lastError      string
+	completedRuns  int
+	nextPeriodicAt time.Time
+}
+
+func pathTidy(s *SolanaAuthBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "tidy",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: s.pathTidyUpdate,
+					Summary:  "Remove expired nonces and lockout records from storage",
+				},
+			},
+		},
+		{
+			Pattern: "tidy-status",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: s.pathTidyStatusRead,
+					Summary:  "Read the status of the last tidy operation",
+				},
+			},
+		},
+	}
+}
+
+func (s *SolanaAuthBackend) pathTidyStatusRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	s.tidy.RLock()
+	defer s.tidy.RUnlock()
+
+	state := "idle"
+	if s.tidy.running {
+		state = "running"
+	}
+
+	respData := map[string]any{
+		"state":           state,
+		"nonces_checked":  s.tidy.noncesChecked,
+		"nonces_deleted":  s.tidy.noncesDeleted,
+		"lockouts_pruned": s.tidy.lockoutsPruned,
+		"total_deleted":   s.tidy.totalDeleted,
+		"completed_runs":  s.tidy.completedRuns,
+		"last_error":      s.tidy.lastError,
+		"last_run_start":  nil,
+		"last_run_end":    nil,
+	}
+
+	if !s.tidy.lastRunStart.IsZero() {
+		respData["last_run_start"] = s.tidy.lastRunStart.Format(time.RFC3339)
+	}
+
+	if !s.tidy.lastRunEnd.IsZero() {
+		respData["last_run_end"] = s.tidy.lastRunEnd.Format(time.RFC3339)
+	}
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+func (s *SolanaAuthBackend) pathTidyUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if !s.startTidy() {
+		return logical.ErrorResponse("tidy operation already in progress"), nil
+	}
+
+	go func() {
+		// The request context is cancelled once the response is returned,
+		// so the sweep runs detached from it.
+		s.runTidy(context.Background(), req.Storage)
+	}()
+
+	resp := &logical.Response{}
+	resp.AddWarning("Tidy operation started. Check tidy-status for progress and results.")
+
+	return logical.RespondWithStatusCode(resp, req, http.StatusAccepted)
+}
+
+func (s *SolanaAuthBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	// Redeemed stateless nonces are only held in the memory of each node, so
+	// they are pruned regardless of the replication state.
+	s.usedNonces.prune(time.Now().Unix())
+
+	// The pending counts are only ever incremented between recounts.
+	if err := s.pendingKeys.recount(ctx, req.Storage, nonceStoragePrefix); err != nil {
+		return err
+	}
+
+	if err := s.pendingSessions.recount(ctx, req.Storage, loginSessionStoragePrefix); err != nil {
+		return err
+	}
+
+	if !s.WriteSafeReplicationState() {
+		return nil
+	}
+
+	s.tidy.RLock()
+	due := time.Now().After(s.tidy.nextPeriodicAt)
+	s.tidy.RUnlock()
+
+	if !due || !s.startTidy() {
+		return nil
+	}
+
+	return s.runTidy(ctx, req.Storage)
+}
+
+func (s *SolanaAuthBackend) startTidy() bool {
+	s.tidy.Lock()
+	defer s.tidy.Unlock()
+
+	if s.tidy.running {
+		return false
+	}
+
+	s.tidy.running = true
+	s.tidy.lastRunStart = time.Now()
+	s.tidy.noncesChecked = 0
+	s.tidy.noncesDeleted = 0
+	s.tidy.lockoutsPruned = 0
+	s.tidy.lastError = ""
+
+	return true
+}
+
+func (s *SolanaAuthBackend) runTidy(ctx context.Context, store logical.Storage) error {
+	var err error
+	for _, prefix := range []string{nonceStoragePrefix, multisigNonceStoragePrefix, loginSessionStoragePrefix} {
+		if err = s.tidyNonces(ctx, store, prefix); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = s.tidyLockouts(ctx, store)
+	}
+
+	s.tidy.Lock()
+	defer s.tidy.Unlock()
+
+	s.tidy.running = false
+	s.tidy.lastRunEnd = time.Now()
+	s.tidy.nextPeriodicAt = s.tidy.lastRunEnd.Add(tidyInterval)
+	s.tidy.completedRuns++
+
+	if err != nil {
+		s.tidy.lastError = err.Error()
+		s.Logger().Error("failed to tidy expired nonces", "error", err)
+	}
+
+	return err
+}
+
+func (s *SolanaAuthBackend) tidyNonces(ctx context.Context, store logical.Storage, prefix string) error {
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list nonces: %w", err)
+	}
+
+	now := time.Now().Unix()
+
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/") {
+			// Login sessions, and nonces written before they were keyed by
+			// identifier, are stored directly beneath the prefix.
+			if err := s.tidyNonce(ctx, store, prefix+key, now); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ids, err := store.List(ctx, prefix+key)
+		if err != nil {
+			return fmt.Errorf("failed to list nonces for %q: %w", strings.TrimSuffix(key, "/"), err)
+		}
+
+		for _, id := range ids {
+			if err := s.tidyNonce(ctx, store, prefix+key+id, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *SolanaAuthBackend) tidyNonce(ctx context.Context, store logical.Storage, storageKey string, now int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entry, err := store.Get(ctx, storageKey)
+	if err != nil {
+		return fmt.Errorf("failed to read nonce %q: %w", storageKey, err)
+	}
+
+	s.recordTidyProgress(1, 0)
+
+	if entry == nil {
+		return nil
+	}
+
+	var nonce NonceEntry
+	if err := entry.DecodeJSON(&nonce); err != nil {
+		return fmt.Errorf("failed to decode nonce %q: %w", storageKey, err)
+	}
+
+	if !nonce.isExpired(now) {
+		return nil
+	}
+
+	if err := store.Delete(ctx, storageKey); err != nil {
+		return fmt.Errorf("failed to delete nonce %q: %w", storageKey, err)
+	}
+
+	s.recordTidyProgress(0, 1)
+
+	return nil
+}
+
+// tidyLockouts removes the lockout records of public keys whose lockout has
+// ended and whose failure counter has reset, so that every key that ever
+// failed a login does not remain in storage.
+func (s *SolanaAuthBackend) tidyLockouts(ctx context.Context, store logical.Storage) error {
+	config, err := s.getConfig(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	keys, err := store.List(ctx, lockoutStoragePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list lockouts: %w", err)
+	}
+
+	for _, pubkey := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		pruned, err := s.tidyLockout(ctx, store, config, pubkey)
+		if err != nil {
+			return err
+		}
+
+		if pruned {
+			s.tidy.Lock()
+			s.tidy.lockoutsPruned++
+			s.tidy.Unlock()
+		}
+	}
+
+	return nil
+}
+
+func (s *SolanaAuthBackend) tidyLockout(ctx context.Context, store logical.Storage, config *AuthConfigEntry, pubkey string) (bool, error) {
+	// Hold the lockout lock so that a concurrent failure is not lost by
+	// deleting the record it just updated.
+	s.lockoutLock.Lock()
+	defer s.lockoutLock.Unlock()
+
+	entry, err := s.getLockout(ctx, store, pubkey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read lockout %q: %w", pubkey, err)
+	}
+
+	if entry == nil || !entry.isStale(time.Now(), config.LockoutCounterReset) {
+		return false, nil
+	}
+
+	if err := store.Delete(ctx, lockoutStoragePrefix+pubkey); err != nil {
+		return false, fmt.Errorf("failed to delete lockout %q: %w", pubkey, err)
+	}
+
+	return true, nil
+}
+
+func (s *SolanaAuthBackend) recordTidyProgress(checked, deleted int) {
+	s.tidy.Lock()
+	defer s.tidy.Unlock()
+
+	s.tidy.noncesChecked += checked
+	s.tidy.noncesDeleted += deleted
+	s.tidy.totalDeleted += deleted
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTidyExpiredNonces(t *testing.T) {
+	backend, storage := getTestBackend(t)
+	allowRolelessLogin(t, backend, storage)
+
+	active := solana.NewWallet()
+	expired := solana.NewWallet()
+
+	_, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "nonce",
+		Storage:   storage,
+		Data: map[string]any{
+			"public_key": active.PublicKey().String(),
+		},
+	})
+	assert.NoError(t, err)
+
+	entry, err := logical.StorageEntryJSON(fmt.Sprintf(nonceStorageFormat, expired.PublicKey().String(), "expired"), &NonceEntry{
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+		ID:        "expired",
+		Nonce:     "expired",
+		PublicKey: expired.PublicKey().String(),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put(context.Background(), entry))
+
+	t.Run("Tidy Through Endpoint", func(t *testing.T) {
+		t.Helper()
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "tidy",
+			Storage:   storage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 202, resp.Data[logical.HTTPStatusCode])
+
+		assert.Eventually(t, func() bool {
+			resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "tidy-status",
+				Storage:   storage,
+			})
+			return err == nil && resp.Data["completed_runs"] == 1 && resp.Data["state"] == "idle"
+		}, time.Second, 10*time.Millisecond)
+
+		resp, err = backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "tidy-status",
+			Storage:   storage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.Data["nonces_checked"])
+		assert.Equal(t, 1, resp.Data["nonces_deleted"])
+		assert.Empty(t, resp.Data["last_error"])
+
+		keys, err := storage.List(context.Background(), nonceStoragePrefix)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{active.PublicKey().String() + "/"}, keys)
+	})
+
+	t.Run("Tidy Legacy Nonce Entries", func(t *testing.T) {
+		t.Helper()
+
+		legacy, err := logical.StorageEntryJSON(nonceStoragePrefix+expired.PublicKey().String(), &NonceEntry{
+			ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+			Nonce:     "legacy",
+			PublicKey: expired.PublicKey().String(),
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, storage.Put(context.Background(), legacy))
+
+		assert.NoError(t, backend.runTidy(context.Background(), storage))
+
+		keys, err := storage.List(context.Background(), nonceStoragePrefix)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{active.PublicKey().String() + "/"}, keys)
+	})
+
+	t.Run("Periodic Tidy Waits for Interval", func(t *testing.T) {
+		t.Helper()
+
+		assert.NoError(t, storage.Put(context.Background(), entry))
+		assert.NoError(t, backend.periodicFunc(context.Background(), &logical.Request{Storage: storage}))
+
+		keys, err := storage.List(context.Background(), nonceStoragePrefix)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 2)
+
+		backend.tidy.nextPeriodicAt = time.Time{}
+		assert.NoError(t, backend.periodicFunc(context.Background(), &logical.Request{Storage: storage}))
+
+		keys, err = storage.List(context.Background(), nonceStoragePrefix)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+	})
+
+	t.Run("Tidy Stale Lockouts", func(t *testing.T) {
+		t.Helper()
+
+		now := time.Now()
+
+		assert.NoError(t, backend.setLockout(context.Background(), storage, "stale", &LockoutEntry{
+			FailedAttempts: 1,
+			LastFailedAt:   now.Add(-time.Hour).Unix(),
+		}))
+		assert.NoError(t, backend.setLockout(context.Background(), storage, "locked", &LockoutEntry{
+			LastFailedAt: now.Add(-time.Hour).Unix(),
+			LockedUntil:  now.Add(time.Hour).Unix(),
+		}))
+		assert.NoError(t, backend.setLockout(context.Background(), storage, "recent", &LockoutEntry{
+			FailedAttempts: 1,
+			LastFailedAt:   now.Unix(),
+		}))
+
+		assert.NoError(t, backend.runTidy(context.Background(), storage))
+
+		keys, err := storage.List(context.Background(), lockoutStoragePrefix)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"locked", "recent"}, keys)
+		assert.Equal(t, 1, backend.tidy.lockoutsPruned)
+	})
+}
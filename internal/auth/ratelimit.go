@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/time/rate"
+)
+
+const (
+	rateLimiterIdleTimeout = 10 * time.Minute
+)
+
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// keyedRateLimiter maintains an independent token bucket for each key,
+// discarding buckets that have been idle long enough to have refilled.
+type keyedRateLimiter struct {
+	sync.Mutex
+
+	limit     rate.Limit
+	burst     int
+	entries   map[string]*rateLimiterEntry
+	lastPrune time.Time
+}
+
+func (l *keyedRateLimiter) allow(key string, limit float64, burst int) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+
+	// Buckets are rebuilt whenever the configured limits change.
+	if l.entries == nil || l.limit != rate.Limit(limit) || l.burst != burst {
+		l.limit = rate.Limit(limit)
+		l.burst = burst
+		l.entries = make(map[string]*rateLimiterEntry)
+	}
+
+	if now.Sub(l.lastPrune) > rateLimiterIdleTimeout {
+		for k, e := range l.entries {
+			if now.Sub(e.lastSeen) > rateLimiterIdleTimeout {
+				delete(l.entries, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &rateLimiterEntry{
+			limiter: rate.NewLimiter(l.limit, l.burst),
+		}
+		l.entries[key] = entry
+	}
+
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
+
+// pendingCounter tracks the number of entries beneath a storage prefix, so
+// that unauthenticated requests can be checked against a cap without listing
+// storage each time. It is loaded from storage on first use and recounted by
+// the periodic function, and is only incremented in between, so it may
+// overcount entries that have since been removed until the next recount.
+type pendingCounter struct {
+	sync.Mutex
+
+	count  int
+	loaded bool
+}
+
+// reserve counts a new entry beneath the prefix, returning false without
+// counting it if max entries are already pending.
+func (c *pendingCounter) reserve(ctx context.Context, store logical.Storage, prefix string, max int) (bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.loaded {
+		if err := c.recountLocked(ctx, store, prefix); err != nil {
+			return false, err
+		}
+	}
+
+	if c.count >= max {
+		return false, nil
+	}
+
+	c.count++
+	return true, nil
+}
+
+// recount reloads the number of entries beneath the prefix from storage.
+func (c *pendingCounter) recount(ctx context.Context, store logical.Storage, prefix string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.recountLocked(ctx, store, prefix)
+}
+
+func (c *pendingCounter) recountLocked(ctx context.Context, store logical.Storage, prefix string) error {
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	c.count = len(keys)
+	c.loaded = true
+	return nil
+}
@@ -0,0 +1,354 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	defaultRPCTimeout       = 10 * time.Second
+	maxMultipleAccountsSize = 100
+)
+
+// rpcClient is the subset of the Solana JSON-RPC API used to evaluate
+// on-chain role bindings.
+type rpcClient interface {
+	getAccountInfo(ctx context.Context, address solana.PublicKey) (*rpcAccount, error)
+	getMultipleAccounts(ctx context.Context, addresses []solana.PublicKey) ([]*rpcAccount, error)
+	getTokenAccountsByOwner(ctx context.Context, owner solana.PublicKey, filter rpcTokenFilter) ([]*rpcKeyedAccount, error)
+}
+
+// rpcTokenFilter selects the token accounts of an owner by either their mint
+// or their token program.
+type rpcTokenFilter struct {
+	Mint      solana.PublicKey
+	ProgramID solana.PublicKey
+}
+
+// rpcAccount is an account returned by the RPC node with its data decoded.
+type rpcAccount struct {
+	Data       []byte
+	Executable bool
+	Lamports   uint64
+	Owner      solana.PublicKey
+}
+
+type rpcKeyedAccount struct {
+	Account *rpcAccount
+	Pubkey  solana.PublicKey
+}
+
+// jsonRPCClient is the default rpcClient that talks to Solana RPC nodes over
+// HTTP, failing over between the endpoints in order.
+type jsonRPCClient struct {
+	backoff    time.Duration
+	commitment string
+	endpoints  []RPCEndpoint
+	httpClient *http.Client
+	maxRetries int
+	requestID  atomic.Uint64
+}
+
+func newJSONRPCClient(config *RPCConfigEntry) *jsonRPCClient {
+	return &jsonRPCClient{
+		backoff:    config.RetryBackoff,
+		commitment: config.Commitment,
+		endpoints:  config.Endpoints,
+		httpClient: &http.Client{Timeout: config.Timeout},
+		maxRetries: config.MaxRetries,
+	}
+}
+
+type jsonRPCRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      uint64 `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type jsonRPCResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *jsonRPCError   `json:"error"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *jsonRPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// jsonRPCAccount is the wire format of an account requested with base-64
+// encoding.
+type jsonRPCAccount struct {
+	Data       []string `json:"data"`
+	Executable bool     `json:"executable"`
+	Lamports   uint64   `json:"lamports"`
+	Owner      string   `json:"owner"`
+}
+
+func (a *jsonRPCAccount) decode() (*rpcAccount, error) {
+	if len(a.Data) != 2 || a.Data[1] != "base64" {
+		return nil, errors.New("unexpected account data encoding")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(a.Data[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode account data: %w", err)
+	}
+
+	owner, err := solana.PublicKeyFromBase58(a.Owner)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account owner: %w", err)
+	}
+
+	return &rpcAccount{
+		Data:       data,
+		Executable: a.Executable,
+		Lamports:   a.Lamports,
+		Owner:      owner,
+	}, nil
+}
+
+// call performs the request against each endpoint in turn until one
+// succeeds, retrying the whole list with exponential backoff. Errors returned
+// by a node for the request itself are not retried.
+func (c *jsonRPCClient) call(ctx context.Context, method string, result any, params ...any) error {
+	body, err := json.Marshal(&jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      c.requestID.Add(1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(c.endpoints) == 0 {
+		return errors.New("no rpc endpoints configured")
+	}
+
+	backoff := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		for i := range c.endpoints {
+			raw, err := c.post(ctx, &c.endpoints[i], body)
+			if err == nil {
+				return json.Unmarshal(raw, result)
+			}
+
+			var rpcErr *jsonRPCError
+			if errors.As(err, &rpcErr) || ctx.Err() != nil || attempt >= c.maxRetries && i == len(c.endpoints)-1 {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *jsonRPCClient) post(ctx context.Context, endpoint *RPCEndpoint, body []byte) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range endpoint.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Errors deliberately omit the URL, which may embed an API key.
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rpc request to %s failed", req.URL.Host)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rpc request to %s failed with status %d", req.URL.Host, resp.StatusCode)
+	}
+
+	var rpcResp jsonRPCResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&rpcResp); err != nil {
+		return nil, fmt.Errorf("failed to decode rpc response from %s: %w", req.URL.Host, err)
+	}
+
+	if rpcResp.Error != nil {
+		return nil, rpcResp.Error
+	}
+
+	return rpcResp.Result, nil
+}
+
+func (c *jsonRPCClient) accountConfig() map[string]any {
+	return map[string]any{
+		"commitment": c.commitment,
+		"encoding":   "base64",
+	}
+}
+
+func (c *jsonRPCClient) getAccountInfo(ctx context.Context, address solana.PublicKey) (*rpcAccount, error) {
+	var result struct {
+		Value *jsonRPCAccount `json:"value"`
+	}
+
+	if err := c.call(ctx, "getAccountInfo", &result, address.String(), c.accountConfig()); err != nil {
+		return nil, err
+	}
+
+	if result.Value == nil {
+		return nil, nil
+	}
+
+	return result.Value.decode()
+}
+
+// getMultipleAccounts fetches the accounts in batches, returning nil for any
+// that do not exist.
+func (c *jsonRPCClient) getMultipleAccounts(ctx context.Context, addresses []solana.PublicKey) ([]*rpcAccount, error) {
+	accounts := make([]*rpcAccount, 0, len(addresses))
+
+	for start := 0; start < len(addresses); start += maxMultipleAccountsSize {
+		batch := addresses[start:min(start+maxMultipleAccountsSize, len(addresses))]
+
+		keys := make([]string, len(batch))
+		for i, address := range batch {
+			keys[i] = address.String()
+		}
+
+		var result struct {
+			Value []*jsonRPCAccount `json:"value"`
+		}
+
+		if err := c.call(ctx, "getMultipleAccounts", &result, keys, c.accountConfig()); err != nil {
+			return nil, err
+		}
+
+		if len(result.Value) != len(batch) {
+			return nil, errors.New("rpc returned an unexpected number of accounts")
+		}
+
+		for _, v := range result.Value {
+			if v == nil {
+				accounts = append(accounts, nil)
+				continue
+			}
+
+			account, err := v.decode()
+			if err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, account)
+		}
+	}
+
+	return accounts, nil
+}
+
+func (c *jsonRPCClient) getTokenAccountsByOwner(ctx context.Context, owner solana.PublicKey, filter rpcTokenFilter) ([]*rpcKeyedAccount, error) {
+	var result struct {
+		Value []struct {
+			Account jsonRPCAccount `json:"account"`
+			Pubkey  string         `json:"pubkey"`
+		} `json:"value"`
+	}
+
+	params := map[string]any{"mint": filter.Mint.String()}
+	if filter.Mint.IsZero() {
+		params = map[string]any{"programId": filter.ProgramID.String()}
+	}
+
+	if err := c.call(ctx, "getTokenAccountsByOwner", &result, owner.String(), params, c.accountConfig()); err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*rpcKeyedAccount, 0, len(result.Value))
+	for _, v := range result.Value {
+		account, err := v.Account.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		pubkey, err := solana.PublicKeyFromBase58(v.Pubkey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token account address: %w", err)
+		}
+
+		accounts = append(accounts, &rpcKeyedAccount{Account: account, Pubkey: pubkey})
+	}
+
+	return accounts, nil
+}
+
+// cachingRPCClient caches the accounts fetched by address.
+type cachingRPCClient struct {
+	rpcClient
+
+	cache *ttlCache[*rpcAccount]
+	ttl   time.Duration
+}
+
+func (c *cachingRPCClient) getAccountInfo(ctx context.Context, address solana.PublicKey) (*rpcAccount, error) {
+	if account, ok := c.cache.get(address.String()); ok {
+		return account, nil
+	}
+
+	account, err := c.rpcClient.getAccountInfo(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.set(address.String(), account, c.ttl)
+	return account, nil
+}
+
+func (c *cachingRPCClient) getMultipleAccounts(ctx context.Context, addresses []solana.PublicKey) ([]*rpcAccount, error) {
+	accounts := make([]*rpcAccount, len(addresses))
+
+	var missing []solana.PublicKey
+	var missingIdx []int
+
+	for i, address := range addresses {
+		if account, ok := c.cache.get(address.String()); ok {
+			accounts[i] = account
+			continue
+		}
+
+		missing = append(missing, address)
+		missingIdx = append(missingIdx, i)
+	}
+
+	if len(missing) == 0 {
+		return accounts, nil
+	}
+
+	fetched, err := c.rpcClient.getMultipleAccounts(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, account := range fetched {
+		c.cache.set(missing[i].String(), account, c.ttl)
+		accounts[missingIdx[i]] = account
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,475 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRPC is an in-process stand-in for a Solana JSON-RPC node serving a
+// fixed set of accounts.
+type fakeRPC struct {
+	sync.Mutex
+	*httptest.Server
+
+	accounts map[solana.PublicKey]*rpcAccount
+	calls    map[string]int
+	header   http.Header
+}
+
+func newFakeRPC(tb testing.TB) *fakeRPC {
+	tb.Helper()
+
+	f := &fakeRPC{
+		accounts: make(map[solana.PublicKey]*rpcAccount),
+		calls:    make(map[string]int),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	tb.Cleanup(f.Close)
+
+	return f
+}
+
+// configureFakeRPC points the backend at a new fakeRPC.
+func configureFakeRPC(tb testing.TB, b *SolanaAuthBackend, storage logical.Storage) *fakeRPC {
+	tb.Helper()
+
+	f := newFakeRPC(tb)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rpc",
+		Storage:   storage,
+		Data: map[string]any{
+			"endpoints": []any{f.URL},
+		},
+	})
+	if err != nil || resp.IsError() {
+		tb.Fatal(err, resp)
+	}
+
+	return f
+}
+
+func (f *fakeRPC) setAccount(address, owner solana.PublicKey, data []byte) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.accounts[address] = &rpcAccount{Data: data, Lamports: 1, Owner: owner}
+}
+
+func (f *fakeRPC) deleteAccount(address solana.PublicKey) {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.accounts, address)
+}
+
+// setTokenAccount creates an SPL token account for the owner holding the
+// amount of the mint, returning its address.
+func (f *fakeRPC) setTokenAccount(owner, mint solana.PublicKey, amount uint64) solana.PublicKey {
+	data := make([]byte, tokenAccountSize)
+	copy(data[0:32], mint[:])
+	copy(data[32:64], owner[:])
+	binary.LittleEndian.PutUint64(data[64:72], amount)
+	data[tokenAccountStateOffset] = 1
+
+	address := solana.NewWallet().PublicKey()
+	f.setAccount(address, solana.TokenProgramID, data)
+
+	return address
+}
+
+// setNFT creates an NFT held by the owner whose metadata names the
+// collection, returning its mint.
+func (f *fakeRPC) setNFT(owner, collection solana.PublicKey, verified bool) solana.PublicKey {
+	mint := solana.NewWallet().PublicKey()
+	f.setTokenAccount(owner, mint, 1)
+
+	var data []byte
+	data = append(data, metadataAccountKey)
+	data = append(data, solana.NewWallet().PublicKey().Bytes()...)
+	data = append(data, mint.Bytes()...)
+	for _, s := range []string{"Test NFT", "TEST", "https://example.com/nft.json"} {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(s)))
+		data = append(data, s...)
+	}
+	data = binary.LittleEndian.AppendUint16(data, 500)
+
+	// A single verified creator with a 100% share.
+	data = append(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = append(data, solana.NewWallet().PublicKey().Bytes()...)
+	data = append(data, 1, 100)
+
+	// Primary sale happened, is mutable, edition nonce and token standard.
+	data = append(data, 1, 1, 1, 255, 1, 0)
+
+	var verifiedFlag byte
+	if verified {
+		verifiedFlag = 1
+	}
+	data = append(data, 1, verifiedFlag)
+	data = append(data, collection.Bytes()...)
+
+	address, _, _ := solana.FindTokenMetadataAddress(mint)
+	f.setAccount(address, solana.TokenMetadataProgramID, data)
+
+	return mint
+}
+
+// setTokenOwnerRecord creates an SPL Governance token owner record for the
+// owner with the deposited amount of the governing token mint.
+func (f *fakeRPC) setTokenOwnerRecord(owner, realm, mint solana.PublicKey, amount uint64) {
+	program := solana.MustPublicKeyFromBase58(defaultGovernanceProgramID)
+
+	address, _, _ := solana.FindProgramAddress([][]byte{
+		[]byte("governance"),
+		realm[:],
+		mint[:],
+		owner[:],
+	}, program)
+
+	data := []byte{tokenOwnerRecordV2}
+	data = append(data, realm.Bytes()...)
+	data = append(data, mint.Bytes()...)
+	data = append(data, owner.Bytes()...)
+	data = binary.LittleEndian.AppendUint64(data, amount)
+	data = append(data, make([]byte, 64)...)
+
+	f.setAccount(address, program, data)
+}
+
+// setSquadsMultisig creates a Squads v4 multisig account with the members
+// and their permission masks, returning its address.
+func (f *fakeRPC) setSquadsMultisig(members map[solana.PublicKey]uint8) solana.PublicKey {
+	data := append([]byte{}, squadsMultisigDiscriminator...)
+	data = append(data, solana.NewWallet().PublicKey().Bytes()...)
+	data = append(data, make([]byte, solana.PublicKeyLength)...)
+	data = binary.LittleEndian.AppendUint16(data, 2)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = binary.LittleEndian.AppendUint64(data, 10)
+	data = binary.LittleEndian.AppendUint64(data, 0)
+	data = append(data, 0, 255)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(members)))
+	for key, permissions := range members {
+		data = append(data, key.Bytes()...)
+		data = append(data, permissions)
+	}
+
+	address := solana.NewWallet().PublicKey()
+	f.setAccount(address, solana.MustPublicKeyFromBase58(defaultSquadsProgramID), data)
+
+	return address
+}
+
+// setUpgradeableProgram creates a BPF upgradeable program and its program
+// data account with the upgrade authority, returning the program address.
+func (f *fakeRPC) setUpgradeableProgram(authority *solana.PublicKey) solana.PublicKey {
+	program := solana.NewWallet().PublicKey()
+	programData, _, _ := solana.FindProgramAddress([][]byte{program[:]}, solana.BPFLoaderUpgradeableProgramID)
+
+	data := binary.LittleEndian.AppendUint32(nil, upgradeableLoaderStateProgram)
+	data = append(data, programData.Bytes()...)
+	f.setAccount(program, solana.BPFLoaderUpgradeableProgramID, data)
+
+	f.setUpgradeAuthority(programData, authority)
+
+	return program
+}
+
+func (f *fakeRPC) setUpgradeAuthority(programData solana.PublicKey, authority *solana.PublicKey) {
+	data := binary.LittleEndian.AppendUint32(nil, upgradeableLoaderStateProgramData)
+	data = binary.LittleEndian.AppendUint64(data, 1000)
+	if authority != nil {
+		data = append(data, 1)
+		data = append(data, authority.Bytes()...)
+	} else {
+		data = append(data, 0)
+	}
+	f.setAccount(programData, solana.BPFLoaderUpgradeableProgramID, data)
+}
+
+func (f *fakeRPC) callCount(method string) int {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.calls[method]
+}
+
+func (f *fakeRPC) lastHeader(name string) string {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.header.Get(name)
+}
+
+func encodeFakeAccount(account *rpcAccount) map[string]any {
+	if account == nil {
+		return nil
+	}
+
+	return map[string]any{
+		"data":       []string{base64.StdEncoding.EncodeToString(account.Data), "base64"},
+		"executable": account.Executable,
+		"lamports":   account.Lamports,
+		"owner":      account.Owner.String(),
+		"rentEpoch":  0,
+	}
+}
+
+func (f *fakeRPC) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     uint64            `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	f.calls[req.Method]++
+	f.header = r.Header.Clone()
+
+	var address string
+	if len(req.Params) > 0 {
+		_ = json.Unmarshal(req.Params[0], &address)
+	}
+
+	var value any
+
+	switch req.Method {
+	case "getAccountInfo":
+		value = encodeFakeAccount(f.accounts[solana.MustPublicKeyFromBase58(address)])
+	case "getMultipleAccounts":
+		var addresses []string
+		_ = json.Unmarshal(req.Params[0], &addresses)
+
+		accounts := make([]map[string]any, len(addresses))
+		for i, address := range addresses {
+			accounts[i] = encodeFakeAccount(f.accounts[solana.MustPublicKeyFromBase58(address)])
+		}
+
+		value = accounts
+	case "getTokenAccountsByOwner":
+		var filter struct {
+			Mint      string `json:"mint"`
+			ProgramID string `json:"programId"`
+		}
+		_ = json.Unmarshal(req.Params[1], &filter)
+
+		owner := solana.MustPublicKeyFromBase58(address)
+		accounts := []map[string]any{}
+
+		for pubkey, account := range f.accounts {
+			if len(account.Data) < tokenAccountSize || !solana.PublicKeyFromBytes(account.Data[32:64]).Equals(owner) {
+				continue
+			}
+
+			if filter.Mint != "" && solana.PublicKeyFromBytes(account.Data[0:32]).String() != filter.Mint {
+				continue
+			}
+
+			if filter.ProgramID != "" && account.Owner.String() != filter.ProgramID {
+				continue
+			}
+
+			accounts = append(accounts, map[string]any{
+				"account": encodeFakeAccount(account),
+				"pubkey":  pubkey.String(),
+			})
+		}
+
+		value = accounts
+	default:
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"error":   map[string]any{"code": -32601, "message": "Method not found"},
+		})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      req.ID,
+		"result": map[string]any{
+			"context": map[string]any{"slot": 1},
+			"value":   value,
+		},
+	})
+}
+
+func TestJSONRPCClient(t *testing.T) {
+	f := newFakeRPC(t)
+
+	client := newJSONRPCClient(&RPCConfigEntry{
+		Commitment: defaultCommitment,
+		Endpoints:  []RPCEndpoint{{URL: f.URL}},
+	})
+
+	t.Run("Get Account Info", func(t *testing.T) {
+		t.Helper()
+
+		address := solana.NewWallet().PublicKey()
+		f.setAccount(address, solana.SystemProgramID, []byte{1, 2, 3})
+
+		account, err := client.getAccountInfo(context.Background(), address)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{1, 2, 3}, account.Data)
+		assert.Equal(t, solana.SystemProgramID, account.Owner)
+	})
+
+	t.Run("Missing Account", func(t *testing.T) {
+		t.Helper()
+
+		account, err := client.getAccountInfo(context.Background(), solana.NewWallet().PublicKey())
+
+		assert.NoError(t, err)
+		assert.Nil(t, account)
+	})
+
+	t.Run("Surface RPC Errors", func(t *testing.T) {
+		t.Helper()
+
+		var result any
+		err := client.call(context.Background(), "unknownMethod", &result)
+
+		assert.ErrorContains(t, err, "Method not found")
+	})
+}
+
+func TestJSONRPCClientFailover(t *testing.T) {
+	f := newFakeRPC(t)
+
+	var failures atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failures.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(failing.Close)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	address := solana.NewWallet().PublicKey()
+	f.setAccount(address, solana.SystemProgramID, []byte{1})
+
+	t.Run("Fail Over To Next Endpoint", func(t *testing.T) {
+		t.Helper()
+
+		client := newJSONRPCClient(&RPCConfigEntry{
+			Commitment: defaultCommitment,
+			Endpoints: []RPCEndpoint{
+				{URL: closed.URL},
+				{URL: failing.URL},
+				{URL: f.URL, Headers: map[string]string{"X-Api-Key": "secret"}},
+			},
+		})
+
+		account, err := client.getAccountInfo(context.Background(), address)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{1}, account.Data)
+		assert.Equal(t, "secret", f.lastHeader("X-Api-Key"))
+	})
+
+	t.Run("Retry With Backoff", func(t *testing.T) {
+		t.Helper()
+
+		failures.Store(0)
+
+		client := newJSONRPCClient(&RPCConfigEntry{
+			Commitment:   defaultCommitment,
+			Endpoints:    []RPCEndpoint{{URL: failing.URL}},
+			MaxRetries:   2,
+			RetryBackoff: time.Millisecond,
+		})
+
+		_, err := client.getAccountInfo(context.Background(), address)
+
+		assert.ErrorContains(t, err, "status 429")
+		assert.EqualValues(t, 3, failures.Load())
+	})
+
+	t.Run("Do Not Retry RPC Errors", func(t *testing.T) {
+		t.Helper()
+
+		failures.Store(0)
+
+		client := newJSONRPCClient(&RPCConfigEntry{
+			Commitment:   defaultCommitment,
+			Endpoints:    []RPCEndpoint{{URL: f.URL}, {URL: failing.URL}},
+			MaxRetries:   2,
+			RetryBackoff: time.Millisecond,
+		})
+
+		var result any
+		err := client.call(context.Background(), "unknownMethod", &result)
+
+		assert.ErrorContains(t, err, "Method not found")
+		assert.Zero(t, failures.Load())
+	})
+}
+
+func TestCachingRPCClient(t *testing.T) {
+	f := newFakeRPC(t)
+
+	var cache ttlCache[*rpcAccount]
+	client := &cachingRPCClient{
+		rpcClient: newJSONRPCClient(&RPCConfigEntry{
+			Commitment: defaultCommitment,
+			Endpoints:  []RPCEndpoint{{URL: f.URL}},
+		}),
+		cache: &cache,
+		ttl:   time.Minute,
+	}
+
+	first, second := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	f.setAccount(first, solana.SystemProgramID, []byte{1})
+	f.setAccount(second, solana.SystemProgramID, []byte{2})
+
+	t.Run("Cache Account Info", func(t *testing.T) {
+		t.Helper()
+
+		for range 2 {
+			account, err := client.getAccountInfo(context.Background(), first)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte{1}, account.Data)
+		}
+
+		assert.Equal(t, 1, f.callCount("getAccountInfo"))
+	})
+
+	t.Run("Fetch Only Uncached Accounts", func(t *testing.T) {
+		t.Helper()
+
+		accounts, err := client.getMultipleAccounts(context.Background(), []solana.PublicKey{first, second})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{1}, accounts[0].Data)
+		assert.Equal(t, []byte{2}, accounts[1].Data)
+
+		_, err = client.getMultipleAccounts(context.Background(), []solana.PublicKey{first, second})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1, f.callCount("getMultipleAccounts"))
+		assert.Equal(t, 1, f.callCount("getAccountInfo"))
+	})
+}
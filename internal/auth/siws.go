@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
+)
+
+const (
+	signInVersion = "1"
+)
+
+// signInInput builds the SIWS input that is returned to the client for the
+// nonce and that the signed message is later expected to match.
+func signInInput(config *AuthConfigEntry, nonce *NonceEntry) *message.SignInInput {
+	return &message.SignInInput{
+		Domain:         config.SIWSDomain,
+		Address:        nonce.PublicKey,
+		Statement:      config.SIWSStatement,
+		URI:            config.SIWSURI,
+		Version:        signInVersion,
+		ChainID:        config.SIWSChainID,
+		Nonce:          nonce.Nonce,
+		IssuedAt:       time.Unix(nonce.IssuedAt, 0).UTC().Format(time.RFC3339),
+		ExpirationTime: time.Unix(nonce.ExpiresAt, 0).UTC().Format(time.RFC3339),
+	}
+}
+
+// verifySignInMessage parses the SIWS message text that was signed by the
+// wallet, checks it against the input issued with the nonce and returns the
+// exact bytes over which the signature must be verified.
+func verifySignInMessage(config *AuthConfigEntry, nonce *NonceEntry, signedMessage string) ([]byte, error) {
+	if signedMessage == "" {
+		return nil, errors.New("missing signed message")
+	}
+
+	// Wallets return the signed message as raw bytes, which clients may
+	// submit base-64 encoded rather than as text.
+	if decoded, err := base64.StdEncoding.DecodeString(signedMessage); err == nil && utf8.Valid(decoded) {
+		if _, err := message.ParseSignInMessage(string(decoded)); err == nil {
+			signedMessage = string(decoded)
+		}
+	}
+
+	parsed, err := message.ParseSignInMessage(signedMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nonces issued before the input was recorded are checked against the
+	// current config instead.
+	expected := nonce.SignIn
+	if expected == nil {
+		expected = signInInput(config, nonce)
+	}
+
+	checks := []struct {
+		name           string
+		actual, wanted string
+	}{
+		{"domain", parsed.Domain, expected.Domain},
+		{"address", parsed.Address, expected.Address},
+		{"statement", parsed.Statement, expected.Statement},
+		{"uri", parsed.URI, expected.URI},
+		{"version", parsed.Version, expected.Version},
+		{"chain id", parsed.ChainID, expected.ChainID},
+		{"nonce", parsed.Nonce, expected.Nonce},
+		{"issued at", parsed.IssuedAt, expected.IssuedAt},
+		{"expiration time", parsed.ExpirationTime, expected.ExpirationTime},
+	}
+
+	for _, check := range checks {
+		if check.actual != check.wanted {
+			return nil, fmt.Errorf("%s mismatch", check.name)
+		}
+	}
+
+	if parsed.NotBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339, parsed.NotBefore)
+		if err != nil {
+			return nil, fmt.Errorf("invalid not before time: %w", err)
+		}
+
+		if time.Now().Add(time.Duration(nonce.ClockSkewLeeway) * time.Second).Before(notBefore) {
+			return nil, errors.New("message is not yet valid")
+		}
+	}
+
+	return []byte(signedMessage), nil
+}
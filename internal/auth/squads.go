@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	defaultSquadsProgramID = "SQDS4ep65T869zMMBKyuUq6aD6EgTu8psMjkvj52pCf"
+)
+
+// Squads v4 member permission bits.
+const (
+	squadsPermissionInitiate uint8 = 1 << iota
+	squadsPermissionVote
+	squadsPermissionExecute
+)
+
+var (
+	squadsMultisigDiscriminator = anchorAccountDiscriminator("Multisig")
+
+	squadsPermissions = map[string]uint8{
+		"initiate": squadsPermissionInitiate,
+		"vote":     squadsPermissionVote,
+		"execute":  squadsPermissionExecute,
+	}
+)
+
+// anchorAccountDiscriminator returns the 8-byte prefix that Anchor programs
+// write to accounts of the named type.
+func anchorAccountDiscriminator(name string) []byte {
+	sum := sha256.Sum256([]byte("account:" + name))
+	return sum[:8]
+}
+
+// parseSquadsPermissions converts permission names into a Squads v4
+// permission mask.
+func parseSquadsPermissions(names []string) (uint8, error) {
+	var mask uint8
+	for _, name := range names {
+		bit, ok := squadsPermissions[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, fmt.Errorf("unknown squads permission %q", name)
+		}
+		mask |= bit
+	}
+	return mask, nil
+}
+
+// decodeSquadsMembers decodes the members of a Squads v4 multisig account
+// and their permission masks.
+func decodeSquadsMembers(data []byte) (map[solana.PublicKey]uint8, error) {
+	if !bytes.HasPrefix(data, squadsMultisigDiscriminator) {
+		return nil, errors.New("not a squads multisig account")
+	}
+
+	r := &borshReader{data: data[len(squadsMultisigDiscriminator):]}
+
+	r.skip(solana.PublicKeyLength) // create key
+	r.skip(solana.PublicKeyLength) // config authority
+	r.u16()                        // threshold
+	r.u32()                        // time lock
+	r.u64()                        // transaction index
+	r.u64()                        // stale transaction index
+
+	if r.bool() {
+		r.skip(solana.PublicKeyLength) // rent collector
+	}
+
+	r.u8() // bump
+
+	count := int(r.u32())
+	if r.err == nil && count*(solana.PublicKeyLength+1) > r.remaining() {
+		return nil, errShortAccountData
+	}
+
+	members := make(map[solana.PublicKey]uint8, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		key := r.pubkey()
+		members[key] = r.u8()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return members, nil
+}
+
+// checkSquadsMembership requires the key to be a member of the Squads v4
+// multisig holding all of the permissions in the mask.
+func checkSquadsMembership(ctx context.Context, client rpcClient, key, program, multisig solana.PublicKey, permissions uint8) error {
+	account, err := client.getAccountInfo(ctx, multisig)
+	if err != nil {
+		return fmt.Errorf("failed to fetch squads multisig: %w", err)
+	}
+
+	if account == nil || !account.Owner.Equals(program) {
+		return &deniedError{fmt.Sprintf("squads multisig %s not found", multisig)}
+	}
+
+	members, err := decodeSquadsMembers(account.Data)
+	if err != nil {
+		return fmt.Errorf("failed to decode squads multisig: %w", err)
+	}
+
+	granted, ok := members[key]
+	if !ok {
+		return &deniedError{fmt.Sprintf("not a member of squads multisig %s", multisig)}
+	}
+
+	if granted&permissions != permissions {
+		return &deniedError{fmt.Sprintf("missing required permissions on squads multisig %s", multisig)}
+	}
+
+	return nil
+}
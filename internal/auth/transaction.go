@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+var (
+	memoV1ProgramID = solana.MustPublicKeyFromBase58("Memo1UhkJRfHyvLMcVucJwxXeuD728EqVDDwQDxFMNo")
+)
+
+// verifyLoginTransaction decodes a base-64 serialized transaction signed in
+// place of an offchain message and checks that it consists solely of a memo
+// of the challenge message, signed only by the public key, against the zero
+// blockhash so that it could never be executed. The serialized message and
+// its signature are returned for verification.
+func verifyLoginTransaction(challenge string, pk solana.PublicKey, encoded string) ([]byte, solana.Signature, error) {
+	if encoded == "" {
+		return nil, solana.Signature{}, errors.New("missing transaction")
+	}
+
+	tx, err := solana.TransactionFromBase64(encoded)
+	if err != nil {
+		return nil, solana.Signature{}, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+
+	if tx.Message.Header.NumRequiredSignatures != 1 || len(tx.Signatures) != 1 {
+		return nil, solana.Signature{}, errors.New("transaction must have exactly one signer")
+	}
+
+	if len(tx.Message.AccountKeys) == 0 || !tx.Message.AccountKeys[0].Equals(pk) {
+		return nil, solana.Signature{}, errors.New("transaction signer does not match public key")
+	}
+
+	if !tx.Message.RecentBlockhash.Equals(solana.Hash{}) {
+		return nil, solana.Signature{}, errors.New("transaction must use the zero blockhash")
+	}
+
+	if tx.Message.IsVersioned() && len(tx.Message.AddressTableLookups) > 0 {
+		return nil, solana.Signature{}, errors.New("transaction cannot use address lookup tables")
+	}
+
+	if len(tx.Message.Instructions) != 1 {
+		return nil, solana.Signature{}, errors.New("transaction must contain exactly one memo instruction")
+	}
+
+	inst := tx.Message.Instructions[0]
+
+	programID, err := tx.Message.ResolveProgramIDIndex(inst.ProgramIDIndex)
+	if err != nil {
+		return nil, solana.Signature{}, err
+	}
+
+	if !programID.Equals(solana.MemoProgramID) && !programID.Equals(memoV1ProgramID) {
+		return nil, solana.Signature{}, errors.New("transaction instruction is not a memo")
+	}
+
+	if !bytes.Equal(inst.Data, []byte(challenge)) {
+		return nil, solana.Signature{}, errors.New("memo does not match challenge")
+	}
+
+	msg, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return nil, solana.Signature{}, err
+	}
+
+	return msg, tx.Signatures[0], nil
+}
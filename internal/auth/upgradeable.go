@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Variants of the BPF upgradeable loader account state.
+const (
+	upgradeableLoaderStateProgram     = 2
+	upgradeableLoaderStateProgramData = 3
+)
+
+// decodeProgramDataAddress decodes the address of the ProgramData account
+// from a BPF upgradeable program account.
+func decodeProgramDataAddress(data []byte) (solana.PublicKey, error) {
+	r := &borshReader{data: data}
+
+	if state := r.u32(); r.err == nil && state != upgradeableLoaderStateProgram {
+		return solana.PublicKey{}, fmt.Errorf("unexpected upgradeable loader state %d", state)
+	}
+
+	address := r.pubkey()
+	return address, r.err
+}
+
+// decodeUpgradeAuthority decodes the upgrade authority of a ProgramData
+// account, returning nil if the program is immutable.
+func decodeUpgradeAuthority(data []byte) (*solana.PublicKey, error) {
+	r := &borshReader{data: data}
+
+	if state := r.u32(); r.err == nil && state != upgradeableLoaderStateProgramData {
+		return nil, fmt.Errorf("unexpected upgradeable loader state %d", state)
+	}
+
+	r.u64() // deployment slot
+
+	if !r.bool() {
+		return nil, r.err
+	}
+
+	authority := r.pubkey()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &authority, nil
+}
+
+// checkUpgradeAuthority requires the key to be the current upgrade authority
+// of the BPF upgradeable program.
+func checkUpgradeAuthority(ctx context.Context, client rpcClient, key, program solana.PublicKey) error {
+	account, err := client.getAccountInfo(ctx, program)
+	if err != nil {
+		return fmt.Errorf("failed to fetch program account: %w", err)
+	}
+
+	if account == nil || !account.Owner.Equals(solana.BPFLoaderUpgradeableProgramID) {
+		return &deniedError{fmt.Sprintf("%s is not an upgradeable program", program)}
+	}
+
+	programData, err := decodeProgramDataAddress(account.Data)
+	if err != nil {
+		return fmt.Errorf("failed to decode program account: %w", err)
+	}
+
+	account, err = client.getAccountInfo(ctx, programData)
+	if err != nil {
+		return fmt.Errorf("failed to fetch program data account: %w", err)
+	}
+
+	if account == nil || !account.Owner.Equals(solana.BPFLoaderUpgradeableProgramID) {
+		return &deniedError{fmt.Sprintf("program data of %s not found", program)}
+	}
+
+	authority, err := decodeUpgradeAuthority(account.Data)
+	if err != nil {
+		return fmt.Errorf("failed to decode program data account: %w", err)
+	}
+
+	if authority == nil {
+		return &deniedError{fmt.Sprintf("program %s is immutable", program)}
+	}
+
+	if !authority.Equals(key) {
+		return &deniedError{fmt.Sprintf("not the upgrade authority of program %s", program)}
+	}
+
+	return nil
+}
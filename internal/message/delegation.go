@@ -0,0 +1,88 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	delegationHeader = "Authorize a delegate key to sign in to Vault on behalf of:"
+)
+
+// Delegation holds the fields of a certificate in which an identity key
+// authorizes a delegate key to login to a Vault mount until it expires.
+type Delegation struct {
+	Identity  string
+	Delegate  string
+	Mount     string
+	ExpiresAt time.Time
+}
+
+// CreateDelegationMessage renders the delegation into the certificate text
+// that is signed by the identity key as an offchain message.
+func CreateDelegationMessage(d *Delegation) string {
+	var b strings.Builder
+
+	b.WriteString(delegationHeader + "\n")
+	b.WriteString(d.Identity + "\n\n")
+
+	for _, field := range delegationFields(d) {
+		b.WriteString(field.label + *field.value + "\n")
+	}
+	b.WriteString("Expiration Time: " + d.ExpiresAt.UTC().Format(time.RFC3339))
+
+	return b.String()
+}
+
+// ParseDelegationMessage parses delegation certificate text back into its
+// fields. Certificates must be in exactly the form rendered by
+// CreateDelegationMessage, optionally followed by a newline as when read
+// from a file.
+func ParseDelegationMessage(text string) (*Delegation, error) {
+	text = strings.TrimSuffix(text, "\n")
+
+	lines := strings.Split(text, "\n")
+	if len(lines) != 6 || lines[0] != delegationHeader || lines[2] != "" {
+		return nil, errors.New("malformed delegation certificate")
+	}
+
+	d := &Delegation{Identity: lines[1]}
+
+	for i, field := range delegationFields(d) {
+		value, ok := strings.CutPrefix(lines[3+i], field.label)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("missing delegation field %q", strings.TrimSuffix(field.label, ": "))
+		}
+		*field.value = value
+	}
+
+	expiration, ok := strings.CutPrefix(lines[5], "Expiration Time: ")
+	if !ok {
+		return nil, errors.New(`missing delegation field "Expiration Time"`)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, expiration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiration time: %w", err)
+	}
+	d.ExpiresAt = expiresAt
+
+	if d.Identity == "" {
+		return nil, errors.New("missing identity")
+	}
+
+	if CreateDelegationMessage(d) != text {
+		return nil, errors.New("delegation certificate is not in canonical form")
+	}
+
+	return d, nil
+}
+
+func delegationFields(d *Delegation) []signInField {
+	return []signInField{
+		{"Delegate: ", &d.Delegate},
+		{"Mount: ", &d.Mount},
+	}
+}
@@ -0,0 +1,62 @@
+package message
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelegationMessage(t *testing.T) {
+	delegation := &Delegation{
+		Identity:  "5Nfx4Lf3YRoYfAJqmKg8Ht6D2Qf5x3nmaGm5cP2S8zqk",
+		Delegate:  "9xQeWvG816bUx9EPjHmaT23yvVM2ZWbrrpZb9PusVFin",
+		Mount:     "auth/solana/",
+		ExpiresAt: time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+	}
+
+	text := CreateDelegationMessage(delegation)
+
+	t.Run("Render Certificate", func(t *testing.T) {
+		t.Helper()
+
+		assert.Equal(t, strings.Join([]string{
+			delegationHeader,
+			"5Nfx4Lf3YRoYfAJqmKg8Ht6D2Qf5x3nmaGm5cP2S8zqk",
+			"",
+			"Delegate: 9xQeWvG816bUx9EPjHmaT23yvVM2ZWbrrpZb9PusVFin",
+			"Mount: auth/solana/",
+			"Expiration Time: 2026-01-02T15:04:05Z",
+		}, "\n"), text)
+	})
+
+	t.Run("Round Trip", func(t *testing.T) {
+		t.Helper()
+
+		parsed, err := ParseDelegationMessage(text)
+
+		assert.NoError(t, err)
+		assert.Equal(t, delegation, parsed)
+
+		parsed, err = ParseDelegationMessage(text + "\n")
+
+		assert.NoError(t, err)
+		assert.Equal(t, delegation, parsed)
+	})
+
+	t.Run("Reject Malformed Certificates", func(t *testing.T) {
+		t.Helper()
+
+		for name, text := range map[string]string{
+			"Missing Header":     strings.TrimPrefix(text, delegationHeader),
+			"Trailing Newlines":  text + "\n\n",
+			"Reordered Fields":   strings.Replace(text, "Delegate: ", "Mount: ", 1),
+			"Invalid Expiration": strings.Replace(text, "2026-01-02T15:04:05Z", "tomorrow", 1),
+			"Non-UTC Expiration": strings.Replace(text, "2026-01-02T15:04:05Z", "2026-01-02T16:04:05+01:00", 1),
+		} {
+			_, err := ParseDelegationMessage(text)
+			assert.Error(t, err, name)
+		}
+	})
+}
@@ -1,38 +1,23 @@
 package message
 
 import (
-	"encoding/binary"
+	"github.com/callensm/vault-plugin-solana/internal/offchain"
 )
 
+// Offchain message formats, which declare the characters a message body may
+// contain to the wallet displaying it.
 const (
-	messageSigningDomain = "\xffsolana offchain"
+	FormatRestrictedASCII = offchain.FormatRestrictedASCII
+	FormatLimitedUTF8     = offchain.FormatLimitedUTF8
+	FormatExtendedUTF8    = offchain.FormatExtendedUTF8
 )
 
 type OffchainMessageOpts struct {
+	Format      uint8
 	MessageBody []byte
 	Version     uint8
 }
 
 func CreateOffchainMessageWithPreamble(opts *OffchainMessageOpts) []byte {
-	// Signing domain (16 bytes) + Version (1 byte) + Format (1 byte) + Len (2 bytes)
-	preamble := make([]byte, 0, 20+len(opts.MessageBody))
-
-	// Signing domain
-	preamble = append(preamble, []byte(messageSigningDomain)...)
-
-	// Header version
-	preamble = append(preamble, byte(opts.Version))
-
-	// Message format
-	preamble = append(preamble, 0x00)
-
-	// Message length
-	lenBytes := make([]byte, 2)
-	binary.LittleEndian.PutUint16(lenBytes, uint16(len(opts.MessageBody)))
-	preamble = append(preamble, lenBytes...)
-
-	// Message body
-	preamble = append(preamble, opts.MessageBody...)
-
-	return preamble
+	return offchain.Encode(opts.Version, opts.Format, opts.MessageBody)
 }
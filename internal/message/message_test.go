@@ -0,0 +1,36 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffchainMessageFormat(t *testing.T) {
+	body := "Sign in to Vault\nNonce: abc"
+
+	t.Run("Default to Restricted ASCII", func(t *testing.T) {
+		t.Helper()
+
+		msg := CreateOffchainMessageWithPreamble(&OffchainMessageOpts{
+			MessageBody: []byte(body),
+			Version:     0,
+		})
+
+		assert.Equal(t, FormatRestrictedASCII, msg[17])
+		assert.Equal(t, body, string(msg[20:]))
+	})
+
+	t.Run("Use Requested Format", func(t *testing.T) {
+		t.Helper()
+
+		msg := CreateOffchainMessageWithPreamble(&OffchainMessageOpts{
+			Format:      FormatLimitedUTF8,
+			MessageBody: []byte(body),
+			Version:     0,
+		})
+
+		assert.Equal(t, FormatLimitedUTF8, msg[17])
+		assert.Equal(t, body, string(msg[20:]))
+	})
+}
@@ -0,0 +1,76 @@
+package message
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+)
+
+// cliSignature is the document printed by `solana sign-offchain-message`
+// when run with `--output json`.
+type cliSignature struct {
+	Signature string `json:"signature"`
+}
+
+// ParseSignature decodes an ed25519 signature supplied as base-58, base-64 or
+// hex, or as the JSON document output by the Solana CLI, detecting which of
+// the encodings is in use.
+func ParseSignature(encoded string) (solana.Signature, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return solana.Signature{}, errors.New("empty signature")
+	}
+
+	if strings.HasPrefix(encoded, "{") {
+		var doc cliSignature
+		if err := json.Unmarshal([]byte(encoded), &doc); err != nil {
+			return solana.Signature{}, fmt.Errorf("invalid signature JSON document: %w", err)
+		}
+
+		if doc.Signature == "" || strings.HasPrefix(strings.TrimSpace(doc.Signature), "{") {
+			return solana.Signature{}, errors.New("signature JSON document is missing a signature")
+		}
+
+		return ParseSignature(doc.Signature)
+	}
+
+	decoders := []struct {
+		name   string
+		decode func(string) ([]byte, error)
+	}{
+		{"hex", func(s string) ([]byte, error) { return hex.DecodeString(strings.TrimPrefix(s, "0x")) }},
+		{"base-58", base58.Decode},
+		{"base-64", base64.StdEncoding.DecodeString},
+		{"unpadded base-64", base64.RawStdEncoding.DecodeString},
+	}
+
+	// Encodings are tried from the most to the least restrictive alphabet so
+	// that a string is only ambiguous if it decodes to 64 bytes under several.
+	var decodedLengths []string
+	for _, d := range decoders {
+		decoded, err := d.decode(encoded)
+		if err != nil {
+			continue
+		}
+
+		if len(decoded) != ed25519.SignatureSize {
+			decodedLengths = append(decodedLengths, fmt.Sprintf("%d bytes as %s", len(decoded), d.name))
+			continue
+		}
+
+		return solana.SignatureFromBytes(decoded), nil
+	}
+
+	if len(decodedLengths) > 0 {
+		return solana.Signature{}, fmt.Errorf("signature must be %d bytes, decoded %s", ed25519.SignatureSize, strings.Join(decodedLengths, ", "))
+	}
+
+	return solana.Signature{}, errors.New("signature is not valid base-58, base-64, hex or Solana CLI JSON")
+}
@@ -0,0 +1,48 @@
+package message
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSignature(t *testing.T) {
+	wallet := solana.NewWallet()
+
+	sig, err := wallet.PrivateKey.Sign([]byte("vault:solana:abc"))
+	assert.NoError(t, err)
+
+	cases := map[string]string{
+		"Base-58":          sig.String(),
+		"Base-64":          base64.StdEncoding.EncodeToString(sig[:]),
+		"Unpadded Base-64": base64.RawStdEncoding.EncodeToString(sig[:]),
+		"Hex":              hex.EncodeToString(sig[:]),
+		"Prefixed Hex":     "0x" + hex.EncodeToString(sig[:]),
+		"Solana CLI JSON":  "{\n  \"signature\": \"" + sig.String() + "\"\n}\n",
+	}
+
+	for name, encoded := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Helper()
+
+			parsed, err := ParseSignature(encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, sig, parsed)
+		})
+	}
+
+	t.Run("Reject Invalid Signatures", func(t *testing.T) {
+		t.Helper()
+
+		for _, encoded := range []string{"", "not a signature!", "abcd", `{"pubkey":"abc"}`, `{"signature":`} {
+			_, err := ParseSignature(encoded)
+			assert.Error(t, err, encoded)
+		}
+
+		_, err := ParseSignature(wallet.PublicKey().String())
+		assert.ErrorContains(t, err, "signature must be 64 bytes")
+	})
+}
@@ -0,0 +1,170 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	signInHeaderSuffix = " wants you to sign in with your Solana account:"
+)
+
+// SignInInput holds the fields of a Sign-In With Solana (SIWS) message.
+type SignInInput struct {
+	Domain         string   `json:"domain"`
+	Address        string   `json:"address"`
+	Statement      string   `json:"statement,omitempty"`
+	URI            string   `json:"uri,omitempty"`
+	Version        string   `json:"version,omitempty"`
+	ChainID        string   `json:"chainId,omitempty"`
+	Nonce          string   `json:"nonce,omitempty"`
+	IssuedAt       string   `json:"issuedAt,omitempty"`
+	ExpirationTime string   `json:"expirationTime,omitempty"`
+	NotBefore      string   `json:"notBefore,omitempty"`
+	RequestID      string   `json:"requestId,omitempty"`
+	Resources      []string `json:"resources,omitempty"`
+}
+
+// CreateSignInMessage renders the SIWS input into the message text that
+// wallets present to the user and sign.
+func CreateSignInMessage(input *SignInInput) string {
+	var b strings.Builder
+
+	b.WriteString(input.Domain + signInHeaderSuffix + "\n")
+	b.WriteString(input.Address)
+
+	if input.Statement != "" {
+		b.WriteString("\n\n" + input.Statement)
+	}
+
+	var fields []string
+	for _, field := range signInFields(input) {
+		if *field.value != "" {
+			fields = append(fields, field.label+*field.value)
+		}
+	}
+
+	if len(input.Resources) > 0 {
+		fields = append(fields, "Resources:")
+		for _, resource := range input.Resources {
+			fields = append(fields, "- "+resource)
+		}
+	}
+
+	if len(fields) > 0 {
+		b.WriteString("\n\n" + strings.Join(fields, "\n"))
+	}
+
+	return b.String()
+}
+
+// ParseSignInMessage parses SIWS message text back into its fields.
+func ParseSignInMessage(text string) (*SignInInput, error) {
+	header, rest, ok := strings.Cut(text, "\n")
+	if !ok || !strings.HasSuffix(header, signInHeaderSuffix) {
+		return nil, errors.New("missing sign in header")
+	}
+
+	input := &SignInInput{
+		Domain: strings.TrimSuffix(header, signInHeaderSuffix),
+	}
+
+	input.Address, rest, _ = strings.Cut(rest, "\n")
+	if input.Domain == "" || input.Address == "" {
+		return nil, errors.New("missing domain or address")
+	}
+
+	if rest == "" {
+		return input, nil
+	}
+
+	if !strings.HasPrefix(rest, "\n") {
+		return nil, errors.New("malformed message body")
+	}
+	rest = rest[1:]
+
+	blocks := strings.Split(rest, "\n\n")
+	if len(blocks) > 2 {
+		return nil, errors.New("malformed message body")
+	}
+
+	if len(blocks) == 2 || !isSignInFieldLine(blocks[0]) {
+		input.Statement = blocks[0]
+		blocks = blocks[1:]
+	}
+
+	if len(blocks) == 0 {
+		return input, nil
+	}
+
+	fields := signInFields(input)
+	lines := strings.Split(blocks[0], "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if line == "Resources:" {
+			for _, resource := range lines[i+1:] {
+				value, ok := strings.CutPrefix(resource, "- ")
+				if !ok {
+					return nil, fmt.Errorf("malformed resource %q", resource)
+				}
+				input.Resources = append(input.Resources, value)
+			}
+			break
+		}
+
+		// Fields must appear in their canonical order and at most once.
+		matched := false
+		for len(fields) > 0 {
+			field := fields[0]
+			fields = fields[1:]
+
+			if value, ok := strings.CutPrefix(line, field.label); ok {
+				*field.value = value
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return nil, fmt.Errorf("unexpected message field %q", line)
+		}
+	}
+
+	return input, nil
+}
+
+type signInField struct {
+	label string
+	value *string
+}
+
+func signInFields(input *SignInInput) []signInField {
+	return []signInField{
+		{"URI: ", &input.URI},
+		{"Version: ", &input.Version},
+		{"Chain ID: ", &input.ChainID},
+		{"Nonce: ", &input.Nonce},
+		{"Issued At: ", &input.IssuedAt},
+		{"Expiration Time: ", &input.ExpirationTime},
+		{"Not Before: ", &input.NotBefore},
+		{"Request ID: ", &input.RequestID},
+	}
+}
+
+func isSignInFieldLine(block string) bool {
+	line, _, _ := strings.Cut(block, "\n")
+	if line == "Resources:" {
+		return true
+	}
+
+	for _, field := range signInFields(&SignInInput{}) {
+		if strings.HasPrefix(line, field.label) {
+			return true
+		}
+	}
+
+	return false
+}
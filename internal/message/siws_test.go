@@ -0,0 +1,67 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignInMessageRoundTrip(t *testing.T) {
+	t.Run("All Fields", func(t *testing.T) {
+		t.Helper()
+
+		input := &SignInInput{
+			Domain:         "vault.example.com",
+			Address:        "9xQeWvG816bUx9EPjHmaT23yvVM2ZWbrrpZb9PusVFin",
+			Statement:      "Sign in to Vault",
+			URI:            "https://vault.example.com",
+			Version:        "1",
+			ChainID:        "mainnet",
+			Nonce:          "abcdef123456",
+			IssuedAt:       "2024-01-01T00:00:00Z",
+			ExpirationTime: "2024-01-01T00:05:00Z",
+			Resources:      []string{"https://a.example.com", "https://b.example.com"},
+		}
+
+		text := CreateSignInMessage(input)
+		assert.Equal(t, `vault.example.com wants you to sign in with your Solana account:
+9xQeWvG816bUx9EPjHmaT23yvVM2ZWbrrpZb9PusVFin
+
+Sign in to Vault
+
+URI: https://vault.example.com
+Version: 1
+Chain ID: mainnet
+Nonce: abcdef123456
+Issued At: 2024-01-01T00:00:00Z
+Expiration Time: 2024-01-01T00:05:00Z
+Resources:
+- https://a.example.com
+- https://b.example.com`, text)
+
+		parsed, err := ParseSignInMessage(text)
+		assert.NoError(t, err)
+		assert.Equal(t, input, parsed)
+	})
+
+	t.Run("Without Statement", func(t *testing.T) {
+		t.Helper()
+
+		input := &SignInInput{
+			Domain:  "vault.example.com",
+			Address: "9xQeWvG816bUx9EPjHmaT23yvVM2ZWbrrpZb9PusVFin",
+			Nonce:   "abcdef123456",
+		}
+
+		parsed, err := ParseSignInMessage(CreateSignInMessage(input))
+		assert.NoError(t, err)
+		assert.Equal(t, input, parsed)
+	})
+
+	t.Run("Reject Out of Order Fields", func(t *testing.T) {
+		t.Helper()
+
+		_, err := ParseSignInMessage("vault.example.com wants you to sign in with your Solana account:\naddr\n\nNonce: abc\nURI: https://vault.example.com")
+		assert.Error(t, err)
+	})
+}
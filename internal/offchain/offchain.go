@@ -0,0 +1,58 @@
+// Package offchain serializes Solana offchain messages. It has no
+// dependencies so that the backend and the Go client can share it.
+package offchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	signingDomain = "\xffsolana offchain"
+
+	// MaxLedgerMessageLength is the length of the longest message that Ledger
+	// devices can display and sign.
+	MaxLedgerMessageLength = 1212
+)
+
+// Offchain message formats, which declare the characters a message body may
+// contain to the wallet displaying it.
+const (
+	FormatRestrictedASCII uint8 = iota
+	FormatLimitedUTF8
+	FormatExtendedUTF8
+)
+
+// Encode prefixes the body with the signing domain, header version, format
+// and length of an offchain message.
+func Encode(version, format uint8, body []byte) []byte {
+	// Signing domain (16 bytes) + Version (1 byte) + Format (1 byte) + Len (2 bytes)
+	msg := make([]byte, 0, len(signingDomain)+4+len(body))
+	msg = append(msg, signingDomain...)
+	msg = append(msg, version, format)
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(body)))
+	msg = append(msg, body...)
+
+	return msg
+}
+
+// Message serializes a message signed by wallets, such as a login challenge
+// or delegation certificate, as a version 0 offchain message. Its format is
+// selected the same way as the Solana CLI and SDKs, so multi-line or
+// non-ASCII messages produce the payload that wallets actually sign.
+func Message(body string) ([]byte, error) {
+	if !utf8.ValidString(body) {
+		return nil, errors.New("message is not valid UTF-8")
+	}
+
+	format := FormatRestrictedASCII
+	if len(body) > MaxLedgerMessageLength {
+		format = FormatExtendedUTF8
+	} else if strings.ContainsFunc(body, func(r rune) bool { return r < 0x20 || r > 0x7e }) {
+		format = FormatLimitedUTF8
+	}
+
+	return Encode(0, format, []byte(body)), nil
+}
@@ -0,0 +1,42 @@
+package offchain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageFormat(t *testing.T) {
+	cases := map[string]struct {
+		body   string
+		format uint8
+	}{
+		"Restricted ASCII": {"vault:solana:abc", FormatRestrictedASCII},
+		"Multi-line":       {"Sign in to Vault\nNonce: abc", FormatLimitedUTF8},
+		"Unicode":          {"Sign in to Vault ✓", FormatLimitedUTF8},
+		"Extended":         {strings.Repeat("a", MaxLedgerMessageLength+1), FormatExtendedUTF8},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Helper()
+
+			msg, err := Message(c.body)
+
+			assert.NoError(t, err)
+			assert.Equal(t, "\xffsolana offchain", string(msg[:16]))
+			assert.Equal(t, uint8(0), msg[16])
+			assert.Equal(t, c.format, msg[17])
+			assert.Equal(t, []byte{byte(len(c.body)), byte(len(c.body) >> 8)}, msg[18:20])
+			assert.Equal(t, c.body, string(msg[20:]))
+		})
+	}
+
+	t.Run("Reject Invalid UTF-8", func(t *testing.T) {
+		t.Helper()
+
+		_, err := Message("vault:solana:\xff")
+		assert.Error(t, err)
+	})
+}
@@ -56,7 +56,7 @@ func pathMessage(s *SolanaSecretsBackend) []*framework.Path {
 				},
 				"signature": {
 					Type:        framework.TypeString,
-					Description: "The base-58 signature of the message being verified",
+					Description: "The signature of the message being verified, encoded as base-58, base-64, hex or the Solana CLI JSON output",
 					Required:    true,
 				},
 			},
@@ -135,9 +135,9 @@ func (s *SolanaSecretsBackend) pathWalletVerifyMessageSignature(ctx context.Cont
 		return logical.ErrorResponse("empty or missing signature"), nil
 	}
 
-	sig, err := solana.SignatureFromBase58(signature)
+	sig, err := message.ParseSignature(signature)
 	if err != nil {
-		return logical.ErrorResponse("invalid signature"), nil
+		return logical.ErrorResponse("invalid signature: %v", err), nil
 	}
 
 	entry, err := s.getWallet(ctx, req.Storage, id)
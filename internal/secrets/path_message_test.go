@@ -2,11 +2,14 @@ package secrets
 
 import (
 	"context"
+	"encoding/hex"
 	"testing"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/callensm/vault-plugin-solana/internal/message"
 )
 
 func TestMessageSigningAndVerification(t *testing.T) {
@@ -98,4 +101,62 @@ func TestMessageSigningAndVerification(t *testing.T) {
 		assert.True(t, resp.Data["verified"].(bool))
 	})
 
+	t.Run("Verify Hex and Solana CLI JSON", func(t *testing.T) {
+		t.Helper()
+
+		for _, signature := range []string{
+			hex.EncodeToString(offchainSignature[:]),
+			`{"signature":"` + offchainSignature.String() + `"}`,
+		} {
+			resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "wallet/test/message/verify",
+				Storage:   storage,
+				Data: map[string]any{
+					"message":   msg,
+					"signature": signature,
+				},
+			})
+
+			assert.NoError(t, err)
+			assert.True(t, resp.Data["verified"].(bool))
+		}
+	})
+
+	t.Run("Sign Multi-line Message in Restricted ASCII Format", func(t *testing.T) {
+		t.Helper()
+
+		body := "first line\nsecond line"
+
+		resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "wallet/test",
+			Storage:   storage,
+		})
+		assert.NoError(t, err)
+
+		pubkey, err := solana.PublicKeyFromBase58(resp.Data["public_key"].(string))
+		assert.NoError(t, err)
+
+		resp, err = backend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "wallet/test/message/sign",
+			Storage:   storage,
+			Data: map[string]any{
+				"message": body,
+			},
+		})
+		assert.NoError(t, err)
+
+		sig, err := solana.SignatureFromBase58(resp.Data["signature"].(string))
+		assert.NoError(t, err)
+
+		// The format is not derived from the body, so that signatures the
+		// engine has already produced remain verifiable.
+		assert.True(t, sig.Verify(pubkey, message.CreateOffchainMessageWithPreamble(&message.OffchainMessageOpts{
+			Format:      message.FormatRestrictedASCII,
+			MessageBody: []byte(body),
+			Version:     0,
+		})))
+	})
 }